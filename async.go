@@ -0,0 +1,207 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// behaviour of AsyncOut when the queue is full
+type OverflowPolicy string
+
+const (
+	OverflowBlock      OverflowPolicy = "block"      // wait for free space
+	OverflowDropNewest OverflowPolicy = "dropNewest" // drop the record being logged
+	OverflowDropOldest OverflowPolicy = "dropOldest" // drop the oldest queued record
+	OverflowDropBelow  OverflowPolicy = "dropBelow"  // drop records below DropLevel, wait for others
+)
+
+type AsyncOutConfig struct {
+	QueueSize int            `json:"queueSize" yaml:"queueSize"`
+	Overflow  OverflowPolicy `json:"overflow" yaml:"overflow"`
+	DropLevel Level          `json:"dropLevel" yaml:"dropLevel"` // for OverflowDropBelow
+}
+
+var DefaultAsyncOutConfig = &AsyncOutConfig{
+	QueueSize: 10000,
+	Overflow:  OverflowDropNewest,
+	DropLevel: LevelWarn,
+}
+
+// wrapper writing records to the output from a dedicated goroutine
+type AsyncOut struct {
+	out     LoggerOut
+	cfg     *AsyncOutConfig
+	queue   chan *Record
+	mu      sync.RWMutex // guards closed, held for reading while queueing
+	closed  bool
+	queued  uint64     // records being put or put into the queue
+	taken   uint64     // records taken from the queue, written or dropped
+	pendMu  sync.Mutex // guards queued and taken
+	drained *sync.Cond
+	stats   outStats // queue drops and flushes
+	done    chan struct{}
+}
+
+func NewAsyncOut(out LoggerOut, cfg *AsyncOutConfig) *AsyncOut {
+	if cfg == nil {
+		cfg = DefaultAsyncOutConfig
+	}
+	l := &AsyncOut{
+		out:   out,
+		cfg:   cfg,
+		queue: make(chan *Record, cfg.QueueSize),
+		done:  make(chan struct{}),
+	}
+	l.drained = sync.NewCond(&l.pendMu)
+	go l.run()
+	return l
+}
+
+func (l *AsyncOut) run() {
+	defer close(l.done)
+	for r := range l.queue {
+		l.out.Log(r)
+		l.take()
+	}
+}
+
+func (l *AsyncOut) put() {
+	l.pendMu.Lock()
+	l.queued++
+	l.pendMu.Unlock()
+}
+
+// record counted by put was dropped
+func (l *AsyncOut) unput() {
+	l.pendMu.Lock()
+	l.queued--
+	l.drained.Broadcast()
+	l.pendMu.Unlock()
+}
+
+func (l *AsyncOut) take() {
+	l.pendMu.Lock()
+	l.taken++
+	l.drained.Broadcast()
+	l.pendMu.Unlock()
+}
+
+// queued and not written records
+func (l *AsyncOut) pending() int {
+	l.pendMu.Lock()
+	defer l.pendMu.Unlock()
+	return int(l.queued - l.taken)
+}
+
+// number of records dropped because of overflow or logged after close
+func (l *AsyncOut) Dropped() uint64 {
+	var n uint64
+	for _, d := range l.stats.snapshot().Dropped {
+		n += d
+	}
+	return n
+}
+
+func (l *AsyncOut) drop(reason string) {
+	l.stats.drop(reason, 1)
+}
+
+// counters of the wrapped output with queue drops and depth,
+// flushes are counted by the wrapper only if the output has no stats
+func (l *AsyncOut) Stats() OutStats {
+	own := l.stats.snapshot()
+	s := OutStats{Flushes: own.Flushes, FlushTime: own.FlushTime}
+	if p, ok := l.out.(StatsProvider); ok {
+		s = p.Stats()
+	}
+	s.Name = l.Name()
+	for reason, n := range own.Dropped {
+		if s.Dropped == nil {
+			s.Dropped = make(map[string]uint64)
+		}
+		s.Dropped[reason] += n
+	}
+	s.QueueDepth += l.pending()
+	return s
+}
+
+func (l *AsyncOut) Log(r *Record) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		l.drop(DropClosed)
+		return
+	}
+	// counted before sending, so it is never taken before it is queued
+	l.put()
+	switch {
+	case l.cfg.Overflow == OverflowBlock,
+		l.cfg.Overflow == OverflowDropBelow && r.Level >= l.cfg.DropLevel:
+		l.queue <- r
+	case l.cfg.Overflow == OverflowDropOldest:
+	loop:
+		for {
+			select {
+			case l.queue <- r:
+				break loop
+			default:
+			}
+			select {
+			case <-l.queue:
+				l.drop(DropQueueFull)
+				l.take()
+			default:
+			}
+		}
+	default:
+		select {
+		case l.queue <- r:
+		default:
+			l.drop(DropQueueFull)
+			l.unput()
+		}
+	}
+}
+
+// wait for records queued before the call and flush the output,
+// records logged meanwhile are not waited for
+func (l *AsyncOut) Flush() {
+	defer l.stats.flushed(time.Now())
+	l.pendMu.Lock()
+	for target := l.queued; l.taken < min(target, l.queued); {
+		l.drained.Wait()
+	}
+	l.pendMu.Unlock()
+	l.out.Flush()
+}
+
+// write queued records and close the output, later records are dropped
+func (l *AsyncOut) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.queue)
+	l.mu.Unlock()
+
+	<-l.done
+	l.out.Flush()
+	return l.out.Close()
+}
+
+func (l *AsyncOut) Reopen() error {
+	if r, ok := l.out.(Reopener); ok {
+		return r.Reopen()
+	}
+	return nil
+}
+
+func (l *AsyncOut) Init(main *Logger) {
+	l.out.Init(main)
+}
+
+func (l *AsyncOut) Name() string {
+	return l.out.Name()
+}
@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// output counting records, each write takes delay
+type slowOut struct {
+	delay   time.Duration
+	written atomic.Int64
+}
+
+func (o *slowOut) Close() error   { return nil }
+func (o *slowOut) Init(_ *Logger) {}
+func (o *slowOut) Name() string   { return "slow" }
+func (o *slowOut) Flush()         {}
+
+func (o *slowOut) Log(_ *Record) {
+	time.Sleep(o.delay)
+	o.written.Add(1)
+}
+
+func TestAsyncOutFlushWhileLogging(t *testing.T) {
+	out := &slowOut{delay: time.Millisecond}
+	async := NewAsyncOut(out, &AsyncOutConfig{QueueSize: 10, Overflow: OverflowBlock})
+	defer async.Close()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					async.Log(&Record{Level: LevelInfo, Message: "test"})
+				}
+			}
+		}()
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	flushed := make(chan struct{})
+	go func() {
+		async.Flush()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("flush is blocked by concurrent logging")
+	}
+}
+
+func TestAsyncOutFlushWritesQueued(t *testing.T) {
+	out := &slowOut{delay: time.Millisecond}
+	async := NewAsyncOut(out, &AsyncOutConfig{QueueSize: 100, Overflow: OverflowBlock})
+	defer async.Close()
+
+	for i := 0; i < 50; i++ {
+		async.Log(&Record{Level: LevelInfo, Message: "test"})
+	}
+	async.Flush()
+	if n := out.written.Load(); n != 50 {
+		t.Fatalf("written %d records before flush returned, want 50", n)
+	}
+}
+
+func TestAsyncOutStats(t *testing.T) {
+	out, err := NewFileOut(&FileOutConfig{FilePath: t.TempDir() + "/app.log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	async := NewAsyncOut(out, &AsyncOutConfig{QueueSize: 10, Overflow: OverflowBlock})
+	for i := 0; i < 5; i++ {
+		async.Log(&Record{Level: LevelInfo, Message: "test"})
+	}
+	async.Flush()
+	async.Close()
+	async.Log(&Record{Level: LevelInfo, Message: "closed"})
+
+	s := async.Stats()
+	if s.Written != 5 {
+		t.Errorf("written %d, want 5", s.Written)
+	}
+	if s.Dropped[DropClosed] != 1 || async.Dropped() != 1 {
+		t.Errorf("dropped %v, total %d, want 1 closed", s.Dropped, async.Dropped())
+	}
+	if s.Flushes != 0 {
+		t.Errorf("flushes %d, want 0: file output counts none", s.Flushes)
+	}
+}
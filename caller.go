@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// source location of a log call
+type Caller struct {
+	File     string
+	Line     int
+	Function string
+}
+
+// short form: dir/file.go:line
+func (c *Caller) String() string {
+	dir, file := filepath.Split(c.File)
+	return filepath.Join(filepath.Base(dir), file) + ":" + strconv.Itoa(c.Line)
+}
+
+var pkgPrefix = reflect.TypeOf(Record{}).PkgPath() + "."
+
+// frame of the package or of the code calling it,
+// e.g. a panic calling Recover or slog calling SlogHandler
+func topFrame(frame runtime.Frame) bool {
+	return strings.HasPrefix(frame.Function, pkgPrefix) ||
+		strings.HasPrefix(frame.Function, "runtime.") ||
+		strings.HasPrefix(frame.Function, "log/slog.")
+}
+
+// first frame outside of the package and its callers, skip - additional frames to skip
+func captureCaller(skip int) *Caller {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	top := true
+	for {
+		frame, more := frames.Next()
+		top = top && topFrame(frame)
+		if !top && !strings.HasPrefix(frame.Function, pkgPrefix) {
+			if skip <= 0 {
+				return &Caller{frame.File, frame.Line, frame.Function}
+			}
+			skip--
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// caller by program counter, e.g. slog.Record.PC
+func callerFromPC(pc uintptr) *Caller {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.File == "" {
+		return nil
+	}
+	return &Caller{frame.File, frame.Line, frame.Function}
+}
+
+// current goroutine stack without the package frames and their callers on top
+func captureStack() string {
+	var pcs [64]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	var sb strings.Builder
+	top := true
+	for {
+		frame, more := frames.Next()
+		if top && topFrame(frame) {
+			if !more {
+				break
+			}
+			continue
+		}
+		top = false
+		sb.WriteString(frame.Function)
+		sb.WriteString("()\n\t")
+		sb.WriteString(frame.File)
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(frame.Line))
+		sb.WriteByte('\n')
+		if !more {
+			break
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// messages of all errors in args including wrapped ones
+func errorChain(a []any) []string {
+	var res []string
+	var walk func(err error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+		res = append(res, err.Error())
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+		default:
+			walk(errors.Unwrap(err))
+		}
+	}
+	for _, v := range a {
+		if err, ok := v.(error); ok {
+			walk(err)
+		}
+	}
+	return res
+}
@@ -2,26 +2,69 @@ package logger
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/ClickHouse/clickhouse-go"
+	"github.com/ClickHouse/clickhouse-go"
 	"github.com/jmoiron/sqlx"
 )
 
 type ClickhouseOutConfig struct {
-	Enabled        bool          `json:"enabled" yaml:"enabled"`
-	ClickhouseAddr string        `json:"clickhouseAddr" yaml:"clickhouseAddr"`
-	Database       string        `json:"database" yaml:"database"`
-	Username       string        `json:"username" yaml:"username"`
-	Password       string        `json:"password" yaml:"password"`
-	Service        string        `json:"service" yaml:"service"`
-	Timeout        time.Duration `json:"timeout" yaml:"timeout"`
-	BatchTime      time.Duration `json:"batchTime" yaml:"batchTime"`
-	BatchBuffer    int           `json:"batchBuffer" yaml:"batchBuffer"`
+	Name           string               `json:"name" yaml:"name"` // instance name, "clickhouse" by default
+	Enabled        bool                 `json:"enabled" yaml:"enabled"`
+	ClickhouseAddr string               `json:"clickhouseAddr" yaml:"clickhouseAddr"`
+	Database       string               `json:"database" yaml:"database"`
+	AltHosts       []string             `json:"altHosts" yaml:"altHosts"`         // failover hosts as host:port
+	OpenStrategy   string               `json:"openStrategy" yaml:"openStrategy"` // hosts order, "in_order" (default) or "random"
+	TLS            ClickhouseTLSConfig  `json:"tls" yaml:"tls"`
+	Username       string               `json:"username" yaml:"username"`
+	Password       string               `json:"password" yaml:"password"` // masked by String and in errors
+	Server         string               `json:"server" yaml:"server"`     // pod, host, node name or ip by default
+	Labels         map[string]string    `json:"labels" yaml:"labels"`     // static columns, e.g. env, region, version
+	Service        string               `json:"service" yaml:"service"`
+	Timeout        time.Duration        `json:"timeout" yaml:"timeout"`
+	BatchTime      time.Duration        `json:"batchTime" yaml:"batchTime"`
+	BatchBuffer    int                  `json:"batchBuffer" yaml:"batchBuffer"`
+	FlushSize      int                  `json:"flushSize" yaml:"flushSize"`           // buffered records triggering flush, 80% of BatchBuffer by default
+	MaxBatch       int                  `json:"maxBatch" yaml:"maxBatch"`             // max records per insert, 0 - unlimited
+	Table          string               `json:"table" yaml:"table"`                   // "logs" by default
+	Partition      string               `json:"partition" yaml:"partition"`           // partition expression, toYYYYMMDD(tm) by default
+	TTL            time.Duration        `json:"ttl" yaml:"ttl"`                       // keep logs, 0 - forever
+	Migrate        bool                 `json:"migrate" yaml:"migrate"`               // convert tm of existing table to DateTime64(3) by copying it and apply ttl
+	ParamsMode     ClickhouseParamsMode `json:"paramsMode" yaml:"paramsMode"`         // "string" (default) or "map"
+	Columns        []ClickhouseColumn   `json:"columns" yaml:"columns"`               // params stored in dedicated columns
+	Retries        int                  `json:"retries" yaml:"retries"`               // insert attempts after network errors
+	RetryBackoff   time.Duration        `json:"retryBackoff" yaml:"retryBackoff"`     // first retry delay, doubled with jitter for each attempt
+	DeadLetter     LoggerOut            `json:"-" yaml:"-"`                           // receives records rejected by the server, closed with the output
+	DeadLetterFile string               `json:"deadLetterFile" yaml:"deadLetterFile"` // json lines file of rejected records if DeadLetter is not set
+	SpillDir       string               `json:"spillDir" yaml:"spillDir"`             // keeps failed batches and buffer overflow until inserted, disabled if empty
+	SpillSize      int64                `json:"spillSize" yaml:"spillSize"`           // max spill size in bytes, records beyond it are dropped, 0 - unlimited
+	LogLevel       Level                `json:"logLevel" yaml:"logLevel"`
+	ForceDebug     bool                 `json:"forceDebug" yaml:"forceDebug"`
 }
 
+type ClickhouseTLSConfig struct {
+	Enabled    bool   `json:"enabled" yaml:"enabled"`
+	CAFile     string `json:"caFile" yaml:"caFile"`     // system roots if empty
+	CertFile   string `json:"certFile" yaml:"certFile"` // client certificate
+	KeyFile    string `json:"keyFile" yaml:"keyFile"`
+	ServerName string `json:"serverName" yaml:"serverName"` // host from address by default
+	SkipVerify bool   `json:"skipVerify" yaml:"skipVerify"`
+}
+
+// unique names of registered driver tls configs
+var clickhouseTLSConfigs atomic.Int64
+
 var DefaultClickhouseConfig = ClickhouseOutConfig{
 	Enabled:        true,
 	ClickhouseAddr: "tcp://localhost:9000",
@@ -29,155 +72,643 @@ var DefaultClickhouseConfig = ClickhouseOutConfig{
 	Username:       "",
 	Password:       "",
 	Service:        "",
+	Table:          "logs",
+	Partition:      "toYYYYMMDD(tm)",
+	ParamsMode:     ClickhouseParamsString,
 	Timeout:        10 * time.Second,
 	BatchTime:      30 * time.Second,
 	BatchBuffer:    10000,
+	FlushSize:      8000,
+	MaxBatch:       5000,
+	SpillSize:      512 << 20,
+	Retries:        3,
+	RetryBackoff:   500 * time.Millisecond,
 }
 
-type logData struct {
-	Service string
-	Server  string
-	Level   Level
-	Prefix  string
-	Params  Params
-	Message string
-	TM      time.Time
+type ClickhouseOut struct {
+	cfg     *ClickhouseOutConfig
+	server  string
+	conn    *sqlx.DB
+	cols    []chColumn
+	data    chan *Record
+	spill   *spillQueue // nil if disabled
+	dead    LoggerOut   // nil if disabled
+	stats   outStats
+	std     *BaseLogger
+	flushMu sync.Mutex // one flush at a time
+	overMu  sync.Mutex // guards overflow, held while buffering with spill enabled
+	// records logged while the buffer is full, kept in order behind it and spilled by flush
+	overflow []*Record
+	mu       sync.RWMutex // guards closed
+	closed   bool
+	trigger  chan struct{} // flush on buffer high-water mark
+	stop     chan struct{}
+	start    sync.Once // background flush starts on Init, after std is set
+	wg       sync.WaitGroup
 }
 
-type ClickhouseOut struct {
-	cfg    *ClickhouseOutConfig
-	server string
-	conn   *sqlx.DB
-	data   chan *logData
-	std    *BaseLogger
-}
-
-const schema = `
-CREATE TABLE IF NOT EXISTS logs(
-	service String,
-	server  String,
-	level   Enum8('debug' = 0, 'info' = 1, 'warn' = 2, 'error' = 3),
-	prefix  String,
-	params  String,
-	message String,
-	tm      DateTime
-) ENGINE = MergeTree()
-ORDER BY tm
-PARTITION BY toYYYYMMDD(tm);
-`
-
-func getServerName() (string, error) {
+// server identity: configured name, kubernetes pod, hostname, kubernetes node,
+// first non-loopback ipv4 or ipv6 address, empty if nothing is found
+func getServerName(cfg *ClickhouseOutConfig) string {
+	if cfg.Server != "" {
+		return cfg.Server
+	}
+	for _, env := range []string{"POD_NAME", "HOSTNAME"} {
+		if name := os.Getenv(env); name != "" {
+			return name
+		}
+	}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	if name := os.Getenv("NODE_NAME"); name != "" { // shared by pods of the node
+		return name
+	}
+
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
-		return "", fmt.Errorf("get intergaces error: %v", err)
+		return ""
 	}
-
+	var ipv6 string
 	for _, a := range addrs {
 		if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
 			if ipnet.IP.To4() != nil {
-				return ipnet.IP.String(), nil
+				return ipnet.IP.String()
+			}
+			if ipv6 == "" && !ipnet.IP.IsLinkLocalUnicast() {
+				ipv6 = ipnet.IP.String()
 			}
 		}
 	}
-	return "", fmt.Errorf("not found ipv4")
+	return ipv6
 }
 
 func NewClickhouseOut(cfg *ClickhouseOutConfig) (*ClickhouseOut, error) {
 	if cfg == nil {
 		cfg = &DefaultClickhouseConfig
 	}
+	if cfg.BatchTime <= 0 || cfg.Timeout <= 0 {
+		c := *cfg // keep caller config intact
+		if c.BatchTime <= 0 {
+			c.BatchTime = DefaultClickhouseConfig.BatchTime
+		}
+		if c.Timeout <= 0 {
+			c.Timeout = DefaultClickhouseConfig.Timeout
+		}
+		cfg = &c
+	}
 
-	connstr := fmt.Sprintf("%s?database=%v&write_timeout=%v",
-		cfg.ClickhouseAddr, cfg.Database, cfg.Timeout.Seconds())
+	connstr, err := cfg.dsn()
+	if err != nil {
+		return nil, cfg.redact(err)
+	}
 	conn, err := sqlx.Open("clickhouse", connstr)
 	if err != nil {
-		return nil, fmt.Errorf("clickhouse connect error: %v", err)
+		return nil, fmt.Errorf("clickhouse connect error: %v", cfg.redact(err))
+	}
+
+	log := &ClickhouseOut{
+		cfg:     cfg,
+		server:  getServerName(cfg),
+		conn:    conn,
+		data:    make(chan *Record, cfg.BatchBuffer),
+		trigger: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	var deadFile *FileOut // opened here, closed on error
+	fail := func(err error) (*ClickhouseOut, error) {
+		conn.Close()
+		if deadFile != nil {
+			deadFile.Close()
+		}
+		log.spill.close()
+		return nil, err
+	}
+
+	if log.cols, err = log.columns(); err != nil {
+		return fail(fmt.Errorf("init columns error: %v", err))
+	}
+	log.dead = cfg.DeadLetter
+	if log.dead == nil && cfg.DeadLetterFile != "" {
+		if deadFile, err = NewFileOut(&FileOutConfig{
+			Name:     "clickhouse dead letter",
+			Enabled:  true,
+			FilePath: cfg.DeadLetterFile,
+			Format:   FormatJSON,
+		}); err != nil {
+			return fail(fmt.Errorf("open dead letter file error: %v", err))
+		}
+		log.dead = deadFile
+	}
+	if cfg.SpillDir != "" {
+		if log.spill, err = newSpillQueue(cfg.SpillDir, cfg.SpillSize); err != nil {
+			return fail(err)
+		}
 	}
 
-	if _, err = conn.Exec(schema); err != nil {
-		return nil, fmt.Errorf("init schema error: %v", err)
+	if _, err = conn.Exec(log.schema()); err != nil {
+		return fail(fmt.Errorf("init schema error: %v", cfg.redact(err)))
+	}
+	for _, m := range log.migrations() {
+		if _, err = conn.Exec(m); err != nil {
+			return fail(fmt.Errorf("migrate schema error: %v", cfg.redact(err)))
+		}
+	}
+	if cfg.Migrate {
+		if err = log.migrateTime(conn); err != nil {
+			return fail(fmt.Errorf("migrate schema error: %v", cfg.redact(err)))
+		}
 	}
 
-	server, err := getServerName()
+	return log, nil
+}
+
+// keep records left after the final flush timed out
+func (l *ClickhouseOut) drain() {
+	l.overMu.Lock()
+	batch := make([]*Record, 0, len(l.data)+len(l.overflow))
+	for n := len(l.data); n > 0; n-- {
+		batch = append(batch, <-l.data)
+	}
+	batch = append(batch, l.overflow...) // queued behind the buffer
+	l.overflow = nil
+	l.overMu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+	if l.spill != nil {
+		l.spillBatch(batch)
+		return
+	}
+	l.std.Errorf("close timeout, %v logs are not written", len(batch))
+	l.stats.drop(DropFailed, len(batch))
+}
+
+// driver connection string with credentials, tls and failover hosts
+func (cfg *ClickhouseOutConfig) dsn() (string, error) {
+	u, err := url.Parse(cfg.ClickhouseAddr)
 	if err != nil {
-		return nil, fmt.Errorf("get server ip error: %v", err)
+		return "", fmt.Errorf("parse clickhouse address error: %v", err)
+	}
+	q := u.Query()
+	q.Set("database", cfg.Database)
+	q.Set("write_timeout", fmt.Sprint(cfg.Timeout.Seconds()))
+	if cfg.Username != "" {
+		q.Set("username", cfg.Username)
+	}
+	if cfg.Password != "" {
+		q.Set("password", cfg.Password)
+	}
+	if len(cfg.AltHosts) > 0 {
+		q.Set("alt_hosts", strings.Join(cfg.AltHosts, ","))
+	}
+	if cfg.OpenStrategy != "" {
+		q.Set("connection_open_strategy", cfg.OpenStrategy)
+	}
+	if cfg.TLS.Enabled {
+		tlsCfg, err := cfg.TLS.config()
+		if err != nil {
+			return "", err
+		}
+		name := fmt.Sprintf("logger-%d", clickhouseTLSConfigs.Add(1))
+		if err := clickhouse.RegisterTLSConfig(name, tlsCfg); err != nil {
+			return "", fmt.Errorf("register tls config error: %v", err)
+		}
+		q.Set("tls_config", name)
+		q.Set("secure", "true")
 	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
 
-	log := &ClickhouseOut{
-		cfg:    cfg,
-		server: server,
-		conn:   conn,
-		data:   make(chan *logData, cfg.BatchBuffer),
+func (cfg *ClickhouseOutConfig) redact(err error) error {
+	return redact(err, cfg.Password, url.QueryEscape(cfg.Password))
+}
+
+// config without methods, formatted by String and GoString
+type clickhouseOutConfig ClickhouseOutConfig
+
+// config dump with masked password
+func (cfg ClickhouseOutConfig) String() string {
+	return fmt.Sprintf("%+v", cfg.masked())
+}
+
+func (cfg ClickhouseOutConfig) GoString() string {
+	return fmt.Sprintf("%#v", cfg.masked())
+}
+
+func (cfg ClickhouseOutConfig) masked() clickhouseOutConfig {
+	if cfg.Password != "" {
+		cfg.Password = secretMask
 	}
+	return clickhouseOutConfig(cfg)
+}
 
-	go func() {
-		for {
-			time.Sleep(cfg.BatchTime)
-			log.flush()
+func (cfg *ClickhouseTLSConfig) config() (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.SkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file error: %v", err)
+		}
+		tlsCfg.RootCAs = x509.NewCertPool()
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in ca file %s", cfg.CAFile)
 		}
-	}()
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate error: %v", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
 
-	return log, nil
+// periodic flush and spill replay until close
+func (l *ClickhouseOut) run() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.cfg.BatchTime)
+	defer ticker.Stop()
+	for {
+		var retry <-chan time.Time
+		var timer *time.Timer
+		if d, ok := l.spill.retryIn(); ok {
+			timer = time.NewTimer(d)
+			retry = timer.C
+		}
+		select {
+		case <-ticker.C:
+			l.Flush()
+		case <-l.trigger:
+			l.Flush()
+		case <-retry:
+			l.Flush()
+		case <-l.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
 }
 
+// stop batching, write buffered records and close connection,
+// records logged after close are written to std output
 func (l *ClickhouseOut) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	close(l.stop)
+	l.wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.Timeout)
+	defer cancel()
+	l.flush(ctx)
+	l.drain()
+	l.spill.close()
+	if l.dead != nil {
+		if err := l.dead.Close(); err != nil {
+			l.std.Errorf("close dead letter error: %v", err)
+		}
+	}
 	return l.conn.Close()
 }
 
-func (l *ClickhouseOut) init(log *Logger) {
+func (l *ClickhouseOut) Init(log *Logger) {
 	l.std = log.New("clickhouse logger").Std()
+	if l.dead != nil {
+		l.dead.Init(log)
+	}
+	l.start.Do(func() {
+		l.mu.RLock()
+		defer l.mu.RUnlock()
+		if !l.closed {
+			l.wg.Add(1)
+			go l.run()
+		}
+	})
 }
 
-func (l *ClickhouseOut) name() string {
+func (l *ClickhouseOut) Name() string {
+	if l.cfg.Name != "" {
+		return l.cfg.Name
+	}
 	return "clickhouse"
 }
 
-func (l *ClickhouseOut) flush() {
-	if len(l.data) == 0 {
+func (l *ClickhouseOut) Flush() {
+	l.mu.RLock()
+	closed := l.closed
+	l.mu.RUnlock()
+	if closed {
 		return
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.Timeout)
 	defer cancel()
+	l.flush(ctx)
+}
 
-	tx, err := l.conn.Begin()
-	if err != nil {
-		l.std.Errorf("start transaction error: %v", err)
+func (l *ClickhouseOut) flush(ctx context.Context) {
+	l.flushMu.Lock()
+	defer l.flushMu.Unlock()
+	defer l.stats.flushed(time.Now())
+
+	l.replay(ctx)
+	// records logged during flush are left for the next one
+	l.overMu.Lock()
+	n := len(l.data)
+	overflow := l.overflow
+	l.overflow = nil
+	l.overMu.Unlock()
+
+	for n > 0 && ctx.Err() == nil {
+		size := l.batchSize(n)
+		batch := make([]*Record, size)
+		for i := range batch {
+			batch[i] = <-l.data
+		}
+		n -= size
+		l.insert(ctx, batch)
+	}
+	for len(overflow) > 0 {
+		if ctx.Err() != nil { // not lost, replayed later
+			l.spillBatch(overflow)
+			return
+		}
+		size := l.batchSize(len(overflow))
+		l.insert(ctx, overflow[:size])
+		overflow = overflow[size:]
+	}
+}
+
+// records per insert
+func (l *ClickhouseOut) batchSize(n int) int {
+	if l.cfg.MaxBatch > 0 && n > l.cfg.MaxBatch {
+		return l.cfg.MaxBatch
+	}
+	return n
+}
+
+func (l *ClickhouseOut) insert(ctx context.Context, batch []*Record) {
+	if l.spill.pending() { // keep order behind spilled records
+		l.spillBatch(batch)
 		return
 	}
-	stmt, err := tx.Prepare("INSERT INTO logs (service, server, level, prefix, params, message, tm) VALUES (?, ?, ?, ?, ?, ?, ?)")
-	if err != nil {
-		l.std.Errorf("prepare stmt error: %v", err)
+	if err := l.insertRetry(ctx, batch); err != nil {
+		l.std.Errorf("insert %v logs error: %v", len(batch), l.cfg.redact(err))
+		switch {
+		case !retryable(err):
+			l.stats.drop(DropRejected, len(batch))
+			l.deadLetter(batch, err)
+		case l.spill != nil:
+			l.spill.failed()
+			l.spillBatch(batch)
+		default:
+			l.stats.drop(DropFailed, len(batch))
+		}
 		return
 	}
-	defer stmt.Close()
+	l.stats.written.Add(uint64(len(batch)))
+	l.std.Debugf("inserted %v logs", len(batch))
+}
 
-	count := 0
-	for len(l.data) > 0 {
-		data := <-l.data
-		if _, err = stmt.ExecContext(ctx, data.Service, data.Server, data.Level.String(), data.Prefix, data.Params.Json(), data.Message, data.TM); err != nil {
-			l.std.Errorf("insert error: %v", err)
-			// skip error
+// insert batch retrying network errors, the delay grows exponentially with jitter
+func (l *ClickhouseOut) insertRetry(ctx context.Context, batch []*Record) error {
+	delay := l.cfg.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := l.insertBlock(ctx, batch)
+		if err == nil {
+			return nil
+		}
+		l.stats.errors.Add(1)
+		if !retryable(err) || attempt > l.cfg.Retries {
+			return err
+		}
+		l.std.Warnf("insert %v logs error, retry %v: %v", len(batch), attempt, l.cfg.redact(err))
+		timer := time.NewTimer(jitter(delay))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-l.stop: // closing, the final flush doesn't wait either
+			timer.Stop()
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// random delay between d/2 and d
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// write records rejected by the server to the dead letter output
+func (l *ClickhouseOut) deadLetter(batch []*Record, err error) {
+	if l.dead == nil {
+		return
+	}
+	msg := l.cfg.redact(err).Error()
+	for _, r := range batch {
+		dr := *r
+		dr.Fields = append(r.Fields[:len(r.Fields):len(r.Fields)], String("clickhouse_error", msg))
+		l.dead.Log(&dr)
+	}
+	l.dead.Flush()
+}
+
+func (l *ClickhouseOut) spillBatch(batch []*Record) {
+	if err := l.spill.push(batch); err != nil {
+		l.std.Errorf("spill %v logs error: %v", len(batch), err)
+		if errors.Is(err, errSpillFull) {
+			l.stats.drop(DropSpillFull, len(batch))
 		} else {
-			count++
+			l.stats.drop(DropFailed, len(batch))
+		}
+	}
+}
+
+// insert spilled segments in order, stops on error until backoff passes
+func (l *ClickhouseOut) replay(ctx context.Context) {
+	for l.spill.ready() && ctx.Err() == nil {
+		batch, err := l.spill.peek()
+		if err != nil { // unreadable segment would block the queue
+			l.stats.errors.Add(1)
+			l.std.Errorf("read spilled logs error: %v", err)
+		} else if len(batch) > 0 {
+			err = l.insertBlock(ctx, batch)
+			switch {
+			case err == nil:
+				l.stats.written.Add(uint64(len(batch)))
+				l.std.Debugf("replayed %v spilled logs", len(batch))
+			case retryable(err):
+				l.stats.errors.Add(1)
+				l.std.Errorf("replay %v spilled logs error: %v", len(batch), l.cfg.redact(err))
+				l.spill.failed()
+				return
+			default: // rejected segment would block the queue
+				l.stats.errors.Add(1)
+				l.stats.drop(DropRejected, len(batch))
+				l.std.Errorf("replay %v spilled logs error: %v", len(batch), l.cfg.redact(err))
+				l.deadLetter(batch, err)
+			}
+		}
+		if err := l.spill.pop(); err != nil {
+			l.std.Errorf("remove spilled logs error: %v", err)
 		}
 	}
-	if err = tx.Commit(); err != nil {
-		l.std.Errorf("commit error: %v", err)
+}
+
+// write batch as a single native block
+func (l *ClickhouseOut) insertBlock(ctx context.Context, batch []*Record) error {
+	conn, err := l.conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection error: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(dc any) error {
+		ch, ok := dc.(clickhouse.Clickhouse)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", dc)
+		}
+		if _, err := ch.Begin(); err != nil {
+			return fmt.Errorf("start transaction error: %w", err)
+		}
+		if _, err := ch.Prepare(l.insertQuery()); err != nil {
+			ch.Rollback()
+			return fmt.Errorf("prepare insert error: %w", err)
+		}
+		block, err := ch.Block()
+		if err != nil {
+			ch.Rollback()
+			return fmt.Errorf("get block error: %w", err)
+		}
+		for _, r := range batch {
+			if err := block.AppendRow(l.row(r)); err != nil {
+				ch.Rollback()
+				return permanentError{fmt.Errorf("append row error: %w", err)}
+			}
+		}
+		if err := ch.Commit(); err != nil {
+			return fmt.Errorf("commit error: %w", err)
+		}
+		return nil
+	})
+}
+
+// error which fails again on retry
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+// server exceptions caused by load or replication, not by the data
+var retryableCodes = map[int32]bool{
+	159: true, // TIMEOUT_EXCEEDED
+	164: true, // READONLY
+	202: true, // TOO_MANY_SIMULTANEOUS_QUERIES
+	203: true, // NO_FREE_CONNECTION
+	209: true, // SOCKET_TIMEOUT
+	210: true, // NETWORK_ERROR
+	241: true, // MEMORY_LIMIT_EXCEEDED
+	242: true, // TABLE_IS_READ_ONLY
+	252: true, // TOO_MANY_PARTS
+	999: true, // KEEPER_EXCEPTION
+}
+
+// network errors are retryable, rejected data and schema errors are not
+func retryable(err error) bool {
+	var perm permanentError
+	if errors.As(err, &perm) {
+		return false
+	}
+	var ex *clickhouse.Exception
+	if errors.As(err, &ex) {
+		return retryableCodes[ex.Code]
+	}
+	return true
+}
+
+func (l *ClickhouseOut) Log(r *Record) {
+	if !r.Level.allowed(l.cfg.LogLevel, l.cfg.ForceDebug, false) {
+		return
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		l.std.write(r)
+		return
+	}
+	if l.spill != nil {
+		l.buffer(r)
 	} else {
-		l.std.Debugf("inserted %v logs", count)
+		select {
+		case l.data <- r:
+		default: // buffer is full, skip
+			l.stats.drop(DropQueueFull, 1)
+		}
 	}
+	if len(l.data) >= l.highWater() {
+		select {
+		case l.trigger <- struct{}{}:
+		default: // flush already requested
+		}
+	}
+}
+
+func (l *ClickhouseOut) Stats() OutStats {
+	s := l.stats.snapshot()
+	s.Name = l.Name()
+	l.overMu.Lock()
+	s.QueueDepth = len(l.data) + len(l.overflow)
+	l.overMu.Unlock()
+	return s
 }
 
-func (l *ClickhouseOut) log(level Level, s string, i *info) {
-	if len(l.data) < l.cfg.BatchBuffer {
-		l.data <- &logData{
-			Service: l.cfg.Service,
-			Server:  l.server,
-			Prefix:  i.prefix,
-			Params:  i.params,
-			Level:   level,
-			Message: s,
-			TM:      time.Now(),
+// buffer record, once the buffer is full records go to overflow until the next flush
+// so they are spilled in order behind the buffered ones
+func (l *ClickhouseOut) buffer(r *Record) {
+	l.overMu.Lock()
+	defer l.overMu.Unlock()
+	if len(l.overflow) == 0 {
+		select {
+		case l.data <- r:
+			return
+		default:
 		}
-	} // else skip
+	}
+	if len(l.overflow) >= cap(l.data) {
+		l.stats.drop(DropQueueFull, 1)
+		return
+	}
+	l.overflow = append(l.overflow, r)
+}
+
+// buffer size triggering flush, not above the buffer capacity
+func (l *ClickhouseOut) highWater() int {
+	if l.cfg.FlushSize > 0 {
+		return min(l.cfg.FlushSize, l.cfg.BatchBuffer)
+	}
+	return l.cfg.BatchBuffer * 4 / 5
 }
@@ -0,0 +1,330 @@
+package logger
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const levelEnum = "Enum8('unknown' = -1, 'debug' = 0, 'info' = 1, 'warn' = 2, 'error' = 3, 'trace' = 4, 'fatal' = 5, 'panic' = 6)"
+
+// storage of record params in the clickhouse table
+type ClickhouseParamsMode string
+
+const (
+	ClickhouseParamsString ClickhouseParamsMode = "string" // params as json in the params column
+	ClickhouseParamsMap    ClickhouseParamsMode = "map"    // params in params_keys and params_values arrays with params_map column on top
+)
+
+// param stored in a dedicated column
+type ClickhouseColumn struct {
+	Param string `json:"param" yaml:"param"`
+	Name  string `json:"name" yaml:"name"` // param name by default
+	Type  string `json:"type" yaml:"type"` // String (default), Int64, Float64 or UInt8 for bools
+}
+
+// table column and its value for a record
+type chColumn struct {
+	name  string
+	typ   string
+	expr  string // materialized column expression, such columns are not inserted
+	value func(r *Record) driver.Value
+}
+
+func levelName(level Level) string {
+	if s := level.String(); s != "" {
+		return s
+	}
+	return "unknown"
+}
+
+func (l *ClickhouseOut) columns() ([]chColumn, error) {
+	for _, c := range l.cfg.Columns {
+		switch c.Type {
+		case "", "String", "Int64", "Float64", "UInt8":
+		default:
+			return nil, fmt.Errorf("unsupported type %s of column %s", c.Type, c.Param)
+		}
+	}
+	cols := []chColumn{
+		{name: "service", typ: "String", value: func(*Record) driver.Value { return l.cfg.Service }},
+		{name: "server", typ: "String", value: func(*Record) driver.Value { return l.server }},
+		{name: "level", typ: levelEnum, value: func(r *Record) driver.Value { return levelName(r.Level) }},
+		{name: "prefix", typ: "String", value: func(r *Record) driver.Value { return r.Prefix }},
+	}
+	switch l.cfg.ParamsMode {
+	case ClickhouseParamsMap:
+		cols = append(cols,
+			chColumn{name: "params_keys", typ: "Array(String)", value: func(r *Record) driver.Value {
+				keys, _ := paramsArrays(r.Fields)
+				return keys
+			}},
+			chColumn{name: "params_values", typ: "Array(String)", value: func(r *Record) driver.Value {
+				_, values := paramsArrays(r.Fields)
+				return values
+			}},
+			chColumn{name: "params_map", typ: "Map(String, String)",
+				expr: "CAST((params_keys, params_values), 'Map(String, String)')"},
+		)
+	default:
+		cols = append(cols, chColumn{name: "params", typ: "String", value: func(r *Record) driver.Value {
+			return fieldsJSON(r.Fields)
+		}})
+	}
+	cols = append(cols,
+		chColumn{name: "message", typ: "String", value: func(r *Record) driver.Value { return r.Message }},
+		chColumn{name: "tm", typ: "DateTime64(3)", value: func(r *Record) driver.Value { return r.Time }},
+		chColumn{name: "caller", typ: "String", value: func(r *Record) driver.Value {
+			if r.Caller != nil {
+				return r.Caller.String()
+			}
+			return ""
+		}},
+		chColumn{name: "stack", typ: "String", value: func(r *Record) driver.Value { return r.Stack }},
+		chColumn{name: "errors", typ: "Array(String)", value: func(r *Record) driver.Value { return r.Errors }},
+	)
+	for _, c := range l.cfg.Columns {
+		param, typ := c.Param, c.Type
+		name := c.Name
+		if name == "" {
+			name = param
+		}
+		if typ == "" {
+			typ = "String"
+		}
+		cols = append(cols, chColumn{name: name, typ: typ, value: func(r *Record) driver.Value {
+			return paramValue(typ, r.Fields, param)
+		}})
+	}
+	labels := make([]string, 0, len(l.cfg.Labels))
+	for name := range l.cfg.Labels {
+		labels = append(labels, name)
+	}
+	sort.Strings(labels)
+	for _, name := range labels {
+		value := l.cfg.Labels[name]
+		cols = append(cols, chColumn{name: name, typ: "String", value: func(*Record) driver.Value { return value }})
+	}
+
+	names := make(map[string]bool, len(cols))
+	for _, c := range cols {
+		if !columnName.MatchString(c.name) {
+			return nil, fmt.Errorf("invalid column name %q", c.name)
+		}
+		if names[c.name] {
+			return nil, fmt.Errorf("duplicate column %s", c.name)
+		}
+		names[c.name] = true
+	}
+	return cols, nil
+}
+
+var columnName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// params as keys and values arrays, later fields override earlier ones
+func paramsArrays(fields []Field) (keys, values []string) {
+	keys = make([]string, 0, len(fields))
+	values = make([]string, 0, len(fields))
+	for _, f := range fields {
+		if i := indexOf(keys, f.Key); i >= 0 {
+			values[i] = f.String()
+			continue
+		}
+		keys = append(keys, f.Key)
+		values = append(values, f.String())
+	}
+	return keys, values
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// value of the last field with key converted to column type, zero if there is no such field
+func paramValue(typ string, fields []Field, key string) driver.Value {
+	var v any
+	for _, f := range fields {
+		if f.Key == key {
+			v = f.Value()
+		}
+	}
+	switch typ {
+	case "Int64":
+		n, _ := paramNumber(v)
+		return n
+	case "Float64":
+		_, f := paramNumber(v)
+		return f
+	case "UInt8":
+		if b, ok := v.(bool); ok && b {
+			return uint8(1)
+		}
+		if n, f := paramNumber(v); n != 0 || f != 0 {
+			return uint8(1)
+		}
+		if s, ok := v.(string); ok {
+			if b, _ := strconv.ParseBool(s); b {
+				return uint8(1)
+			}
+		}
+		return uint8(0)
+	}
+	if v == nil {
+		return ""
+	}
+	return Any(key, v).String()
+}
+
+// value of any numeric kind or numeric string as integer and float, zeros otherwise
+func paramNumber(v any) (int64, float64) {
+	if v == nil {
+		return 0, 0
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint()), float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float()), rv.Float()
+	case reflect.String:
+		if i, err := strconv.ParseInt(rv.String(), 10, 64); err == nil {
+			return i, float64(i)
+		}
+		f, _ := strconv.ParseFloat(rv.String(), 64)
+		return int64(f), f
+	}
+	return 0, 0
+}
+
+func (l *ClickhouseOut) table() string {
+	if l.cfg.Table != "" {
+		return l.cfg.Table
+	}
+	return "logs"
+}
+
+func (l *ClickhouseOut) schema() string {
+	return l.createQuery(l.table())
+}
+
+func (l *ClickhouseOut) createQuery(table string) string {
+	partition := l.cfg.Partition
+	if partition == "" {
+		partition = "toYYYYMMDD(tm)"
+	}
+	defs := make([]string, len(l.cols))
+	for i, c := range l.cols {
+		defs[i] = "\t" + c.def()
+	}
+	schema := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n) ENGINE = MergeTree()\nORDER BY tm\nPARTITION BY %s",
+		table, strings.Join(defs, ",\n"), partition)
+	if l.cfg.TTL > 0 {
+		schema += "\n" + l.ttl()
+	}
+	return schema
+}
+
+func (l *ClickhouseOut) ttl() string {
+	return fmt.Sprintf("TTL toDateTime(tm) + toIntervalSecond(%d)", int64(l.cfg.TTL.Seconds()))
+}
+
+// schema updates for tables created by previous versions
+func (l *ClickhouseOut) migrations() []string {
+	table := l.table()
+	res := make([]string, 0, len(l.cols)+3)
+	for _, c := range l.cols {
+		res = append(res, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s", table, c.def()))
+	}
+	// extending enum is a metadata only change
+	res = append(res, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN level %s", table, levelEnum))
+	if l.cfg.Migrate && l.cfg.TTL > 0 {
+		res = append(res, fmt.Sprintf("ALTER TABLE %s MODIFY %s", table, l.ttl()))
+	}
+	return res
+}
+
+// tm is a key column, its type can't be altered in place: data is copied
+// to a new table which replaces the old one, the old table is kept as <table>_old
+// to be dropped manually. Records inserted by other writers during the copy are
+// left in the old table.
+func (l *ClickhouseOut) migrateTime(conn *sqlx.DB) error {
+	table := l.table()
+	var typ string
+	if err := conn.Get(&typ, "SELECT type FROM system.columns "+
+		"WHERE database = currentDatabase() AND table = ? AND name = 'tm'", table); err != nil {
+		return fmt.Errorf("get tm type error: %w", err)
+	}
+	if typ == "DateTime64(3)" {
+		return nil
+	}
+	cols := l.insertColumns()
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.name
+	}
+	list := strings.Join(names, ", ")
+	tmp, old := table+"_new", table+"_old"
+	for _, q := range []string{
+		"DROP TABLE IF EXISTS " + tmp, // left by interrupted migration
+		l.createQuery(tmp),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", tmp, list, list, table),
+		fmt.Sprintf("RENAME TABLE %s TO %s, %s TO %s", table, old, tmp, table),
+	} {
+		if _, err := conn.Exec(q); err != nil {
+			return fmt.Errorf("convert tm error: %w", err)
+		}
+	}
+	return nil
+}
+
+// column definition for create and alter queries
+func (c *chColumn) def() string {
+	if c.expr != "" {
+		return c.name + " " + c.typ + " MATERIALIZED " + c.expr
+	}
+	return c.name + " " + c.typ
+}
+
+// inserted columns
+func (l *ClickhouseOut) insertColumns() []chColumn {
+	cols := make([]chColumn, 0, len(l.cols))
+	for _, c := range l.cols {
+		if c.expr == "" {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+func (l *ClickhouseOut) insertQuery() string {
+	cols := l.insertColumns()
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.name
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", l.table(), strings.Join(names, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "))
+}
+
+func (l *ClickhouseOut) row(r *Record) []driver.Value {
+	row := make([]driver.Value, 0, len(l.cols))
+	for _, c := range l.cols {
+		if c.expr == "" {
+			row = append(row, c.value(r))
+		}
+	}
+	return row
+}
@@ -0,0 +1,193 @@
+package logger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type userID uint32
+
+func TestParamValue(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value any
+		want  any
+	}{
+		{"Int64", 42, int64(42)},
+		{"Int64", int32(-7), int64(-7)},
+		{"Int64", uint64(5), int64(5)},
+		{"Int64", userID(9), int64(9)},
+		{"Int64", float32(2.5), int64(2)},
+		{"Int64", 1500 * time.Millisecond, int64(1500000000)},
+		{"Int64", "12", int64(12)},
+		{"Int64", "x", int64(0)},
+		{"Int64", nil, int64(0)},
+		{"Float64", uint8(3), float64(3)},
+		{"Float64", float32(0.5), float64(0.5)},
+		{"Float64", int16(-2), float64(-2)},
+		{"Float64", "1.25", 1.25},
+		{"UInt8", true, uint8(1)},
+		{"UInt8", false, uint8(0)},
+		{"UInt8", uint(2), uint8(1)},
+		{"UInt8", 0.0, uint8(0)},
+		{"UInt8", "true", uint8(1)},
+		{"String", uint64(5), "5"},
+		{"String", nil, ""},
+	}
+	for _, tt := range tests {
+		var params Params
+		if tt.value != nil {
+			params = Params{"key": tt.value}
+		}
+		if got := paramValue(tt.typ, params.fields(), "key"); got != tt.want {
+			t.Errorf("%s of %T(%v): got %T(%v), want %T(%v)", tt.typ, tt.value, tt.value, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func testClickhouseOut(t *testing.T, cfg *ClickhouseOutConfig) *ClickhouseOut {
+	t.Helper()
+	l := &ClickhouseOut{cfg: cfg, server: "host"}
+	var err error
+	if l.cols, err = l.columns(); err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestClickhouseSchema(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *ClickhouseOutConfig
+		schema     string
+		migrations []string
+		insert     string
+	}{
+		{
+			name: "string params",
+			cfg:  &ClickhouseOutConfig{ParamsMode: ClickhouseParamsString},
+			schema: "CREATE TABLE IF NOT EXISTS logs (\n" +
+				"\tservice String,\n\tserver String,\n\tlevel " + levelEnum + ",\n\tprefix String,\n" +
+				"\tparams String,\n\tmessage String,\n\ttm DateTime64(3),\n\tcaller String,\n\tstack String,\n\terrors Array(String)\n" +
+				") ENGINE = MergeTree()\nORDER BY tm\nPARTITION BY toYYYYMMDD(tm)",
+			migrations: []string{
+				"ALTER TABLE logs ADD COLUMN IF NOT EXISTS service String",
+				"ALTER TABLE logs ADD COLUMN IF NOT EXISTS server String",
+				"ALTER TABLE logs ADD COLUMN IF NOT EXISTS level " + levelEnum,
+				"ALTER TABLE logs ADD COLUMN IF NOT EXISTS prefix String",
+				"ALTER TABLE logs ADD COLUMN IF NOT EXISTS params String",
+				"ALTER TABLE logs ADD COLUMN IF NOT EXISTS message String",
+				"ALTER TABLE logs ADD COLUMN IF NOT EXISTS tm DateTime64(3)",
+				"ALTER TABLE logs ADD COLUMN IF NOT EXISTS caller String",
+				"ALTER TABLE logs ADD COLUMN IF NOT EXISTS stack String",
+				"ALTER TABLE logs ADD COLUMN IF NOT EXISTS errors Array(String)",
+				"ALTER TABLE logs MODIFY COLUMN level " + levelEnum,
+			},
+			insert: "INSERT INTO logs (service, server, level, prefix, params, message, tm, caller, stack, errors) " +
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		},
+		{
+			name: "map params with promoted columns and labels",
+			cfg: &ClickhouseOutConfig{
+				ParamsMode: ClickhouseParamsMap,
+				Table:      "app_logs",
+				Partition:  "toYYYYMM(tm)",
+				TTL:        24 * time.Hour,
+				Migrate:    true,
+				Columns:    []ClickhouseColumn{{Param: "user_id", Type: "Int64"}, {Param: "path", Name: "url"}},
+				Labels:     map[string]string{"region": "eu", "env": "prod"},
+			},
+			schema: "CREATE TABLE IF NOT EXISTS app_logs (\n" +
+				"\tservice String,\n\tserver String,\n\tlevel " + levelEnum + ",\n\tprefix String,\n" +
+				"\tparams_keys Array(String),\n\tparams_values Array(String),\n" +
+				"\tparams_map Map(String, String) MATERIALIZED CAST((params_keys, params_values), 'Map(String, String)'),\n" +
+				"\tmessage String,\n\ttm DateTime64(3),\n\tcaller String,\n\tstack String,\n\terrors Array(String),\n" +
+				"\tuser_id Int64,\n\turl String,\n\tenv String,\n\tregion String\n" +
+				") ENGINE = MergeTree()\nORDER BY tm\nPARTITION BY toYYYYMM(tm)\n" +
+				"TTL toDateTime(tm) + toIntervalSecond(86400)",
+			migrations: []string{
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS service String",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS server String",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS level " + levelEnum,
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS prefix String",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS params_keys Array(String)",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS params_values Array(String)",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS params_map Map(String, String) " +
+					"MATERIALIZED CAST((params_keys, params_values), 'Map(String, String)')",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS message String",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS tm DateTime64(3)",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS caller String",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS stack String",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS errors Array(String)",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS user_id Int64",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS url String",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS env String",
+				"ALTER TABLE app_logs ADD COLUMN IF NOT EXISTS region String",
+				"ALTER TABLE app_logs MODIFY COLUMN level " + levelEnum,
+				"ALTER TABLE app_logs MODIFY TTL toDateTime(tm) + toIntervalSecond(86400)",
+			},
+			insert: "INSERT INTO app_logs (service, server, level, prefix, params_keys, params_values, message, tm, " +
+				"caller, stack, errors, user_id, url, env, region) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := testClickhouseOut(t, tt.cfg)
+			if got := l.schema(); got != tt.schema {
+				t.Errorf("schema\n%s\nwant\n%s", got, tt.schema)
+			}
+			if got := l.migrations(); !reflect.DeepEqual(got, tt.migrations) {
+				t.Errorf("migrations\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.migrations, "\n"))
+			}
+			if got := l.insertQuery(); got != tt.insert {
+				t.Errorf("insert\n%s\nwant\n%s", got, tt.insert)
+			}
+		})
+	}
+}
+
+func TestClickhouseRow(t *testing.T) {
+	l := testClickhouseOut(t, &ClickhouseOutConfig{
+		ParamsMode: ClickhouseParamsMap,
+		Service:    "api",
+		Columns:    []ClickhouseColumn{{Param: "user_id", Type: "Int64"}},
+		Labels:     map[string]string{"env": "prod"},
+	})
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := l.row(&Record{
+		Time:    tm,
+		Level:   LevelWarn,
+		Prefix:  "db",
+		Fields:  []Field{Int("user_id", 5), String("path", "/"), Int("user_id", 6)},
+		Message: "slow",
+		Caller:  &Caller{File: "/src/app/db.go", Line: 3},
+		Errors:  []string{"timeout"},
+	})
+	want := []any{"api", "host", "warn", "db", []string{"user_id", "path"}, []string{"6", "/"},
+		"slow", tm, "app/db.go:3", "", []string{"timeout"}, int64(6), "prod"}
+	if len(row) != len(want) {
+		t.Fatalf("row of %d values, want %d", len(row), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(any(row[i]), want[i]) {
+			t.Errorf("column %s: %#v, want %#v", l.insertColumns()[i].name, row[i], want[i])
+		}
+	}
+}
+
+func TestClickhouseColumnsInvalid(t *testing.T) {
+	for _, cfg := range []*ClickhouseOutConfig{
+		{Columns: []ClickhouseColumn{{Param: "n", Type: "Int32"}}},
+		{Columns: []ClickhouseColumn{{Param: "message"}}},
+		{Columns: []ClickhouseColumn{{Param: "user id"}}},
+		{Labels: map[string]string{"level": "x"}},
+		{Columns: []ClickhouseColumn{{Param: "env"}}, Labels: map[string]string{"env": "prod"}},
+	} {
+		l := &ClickhouseOut{cfg: cfg}
+		if _, err := l.columns(); err == nil {
+			t.Errorf("columns %v, labels %v accepted", cfg.Columns, cfg.Labels)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go"
+)
+
+func TestClickhouseDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ClickhouseOutConfig
+		want url.Values
+	}{
+		{
+			name: "defaults",
+			cfg:  ClickhouseOutConfig{ClickhouseAddr: "tcp://127.0.0.1:9000", Database: "logs", Timeout: 5 * time.Second},
+			want: url.Values{"database": {"logs"}, "write_timeout": {"5"}},
+		},
+		{
+			name: "credentials and failover",
+			cfg: ClickhouseOutConfig{
+				ClickhouseAddr: "tcp://ch1:9000?debug=true",
+				Database:       "logs",
+				Timeout:        1500 * time.Millisecond,
+				Username:       "writer",
+				Password:       "p@ss&w=rd?",
+				AltHosts:       []string{"ch2:9000", "ch3:9000"},
+				OpenStrategy:   "in_order",
+			},
+			want: url.Values{
+				"debug":                    {"true"},
+				"database":                 {"logs"},
+				"write_timeout":            {"1.5"},
+				"username":                 {"writer"},
+				"password":                 {"p@ss&w=rd?"},
+				"alt_hosts":                {"ch2:9000,ch3:9000"},
+				"connection_open_strategy": {"in_order"},
+			},
+		},
+		{
+			name: "tls",
+			cfg: ClickhouseOutConfig{
+				ClickhouseAddr: "tcp://ch1:9440",
+				Database:       "logs",
+				Timeout:        time.Second,
+				TLS:            ClickhouseTLSConfig{Enabled: true, SkipVerify: true},
+			},
+			want: url.Values{"database": {"logs"}, "write_timeout": {"1"}, "secure": {"true"}, "tls_config": {"logger-*"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := tt.cfg.dsn()
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr, _ := url.Parse(tt.cfg.ClickhouseAddr)
+			if u.Host != addr.Host || u.Scheme != addr.Scheme {
+				t.Errorf("address %s, want %s", dsn, tt.cfg.ClickhouseAddr)
+			}
+			got := u.Query()
+			if len(got) != len(tt.want) {
+				t.Errorf("params %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if ok, _ := filepath.Match(v[0], got.Get(k)); !ok || len(got[k]) != 1 {
+					t.Errorf("%s=%v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestClickhouseDSNErrors(t *testing.T) {
+	for _, cfg := range []ClickhouseOutConfig{
+		{ClickhouseAddr: "tcp://ch1:9000\n"},
+		{ClickhouseAddr: "tcp://ch1:9440", TLS: ClickhouseTLSConfig{Enabled: true, CAFile: filepath.Join(t.TempDir(), "ca.pem")}},
+	} {
+		if _, err := cfg.dsn(); err == nil {
+			t.Errorf("dsn of %v succeeded", cfg)
+		}
+	}
+}
+
+func TestClickhouseRedact(t *testing.T) {
+	cfg := ClickhouseOutConfig{ClickhouseAddr: "tcp://ch1:9000", Username: "writer", Password: "p@ss&w=rd"}
+	dsn, err := cfg.dsn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{
+		fmt.Errorf("connect %s error", dsn),
+		fmt.Errorf("auth failed for password %s", cfg.Password),
+	} {
+		got := cfg.redact(err).Error()
+		if strings.Contains(got, "p@ss") || strings.Contains(got, url.QueryEscape(cfg.Password)) || !strings.Contains(got, secretMask) {
+			t.Errorf("redacted error %q", got)
+		}
+	}
+	plain := errors.New("no secrets")
+	if cfg.redact(plain) != plain {
+		t.Error("error without password is replaced")
+	}
+	if cfg.redact(nil) != nil {
+		t.Error("nil error is replaced")
+	}
+	for _, s := range []string{cfg.String(), fmt.Sprint(cfg), fmt.Sprintf("%#v", cfg), fmt.Sprintf("%v", &cfg)} {
+		if strings.Contains(s, cfg.Password) {
+			t.Errorf("password in %s", s)
+		}
+	}
+}
+
+func TestClickhouseRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network", errors.New("read tcp: connection reset by peer"), true},
+		{"too many parts", &clickhouse.Exception{Code: 252, Name: "DB::Exception"}, true},
+		{"memory limit wrapped", fmt.Errorf("insert: %w", &clickhouse.Exception{Code: 241}), true},
+		{"keeper", &clickhouse.Exception{Code: 999}, true},
+		{"unknown column", &clickhouse.Exception{Code: 16, Name: "DB::Exception"}, false},
+		{"type mismatch", &clickhouse.Exception{Code: 53}, false},
+		{"bad row", permanentError{errors.New("column tm: unexpected type")}, false},
+		{"bad row wrapped", fmt.Errorf("block: %w", permanentError{errors.New("bad value")}), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("%s: retryable %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,45 +1,80 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
 type Config struct {
 	LogLevel   string              `json:"logLevel" yaml:"loglevel"`
 	ForceDebug bool                `json:"forceDebug" yaml:"forceDebug"`
+	Format     Format              `json:"format" yaml:"format"`
+	Caller     bool                `json:"caller" yaml:"caller"`
+	StackLevel string              `json:"stackLevel" yaml:"stackLevel"` // empty - no stack traces
 	Syslog     SyslogOutConfig     `json:"syslog" yaml:"syslog"`
 	File       FileOutConfig       `json:"file" yaml:"file"`
+	Syslogs    []SyslogOutConfig   `json:"syslogs" yaml:"syslogs"` // additional syslog outputs
+	Files      []FileOutConfig     `json:"files" yaml:"files"`     // additional file outputs
 	Clickhouse ClickhouseOutConfig `json:"clickhouse" yaml:"clickhouse"`
 }
 
+// output level with fallback to the top-level config
+func (cfg *Config) level(level Level, forceDebug bool) (Level, bool) {
+	if level == LevelUnknown {
+		level = NewLevel(cfg.LogLevel)
+	}
+	return level, forceDebug || cfg.ForceDebug
+}
+
 func (cfg *Config) NewLogger() (*Logger, error) {
 	outs := make([]LoggerOut, 0)
 	outs = append(outs, NewStdOut(&StdOutConfig{
 		LogLevel:   NewLevel(cfg.LogLevel),
 		ForceDebug: cfg.ForceDebug,
+		Format:     cfg.Format,
 	}))
-	if cfg.Syslog.Enabled {
-		syslog, err := NewSyslogOut(&cfg.Syslog)
+	for _, syslogCfg := range append([]SyslogOutConfig{cfg.Syslog}, cfg.Syslogs...) {
+		if !syslogCfg.Enabled {
+			continue
+		}
+		syslogCfg := syslogCfg // kept by the output
+		syslogCfg.LogLevel, syslogCfg.ForceDebug = cfg.level(syslogCfg.LogLevel, syslogCfg.ForceDebug)
+		syslog, err := NewSyslogOut(&syslogCfg)
 		if err != nil {
-			return nil, fmt.Errorf("init syslog error: %w", err)
+			return nil, fmt.Errorf("init syslog %s error: %w", syslogCfg.Name, err)
 		}
 		outs = append(outs, syslog)
 	}
-	if cfg.File.Enabled {
-		file, err := NewFileOut(&cfg.File)
+	for _, fileCfg := range append([]FileOutConfig{cfg.File}, cfg.Files...) {
+		if !fileCfg.Enabled {
+			continue
+		}
+		fileCfg := fileCfg // kept by the output
+		fileCfg.LogLevel, fileCfg.ForceDebug = cfg.level(fileCfg.LogLevel, fileCfg.ForceDebug)
+		file, err := NewFileOut(&fileCfg)
 		if err != nil {
-			return nil, fmt.Errorf("init file error: %w", err)
+			return nil, fmt.Errorf("init file %s error: %w", fileCfg.FilePath, err)
 		}
 		outs = append(outs, file)
 	}
 	if cfg.Clickhouse.Enabled {
-		clickhouse, err := NewClickhouseOut(&cfg.Clickhouse)
+		clickhouseCfg := cfg.Clickhouse
+		clickhouseCfg.LogLevel, clickhouseCfg.ForceDebug = cfg.level(clickhouseCfg.LogLevel, clickhouseCfg.ForceDebug)
+		clickhouse, err := NewClickhouseOut(&clickhouseCfg)
 		if err != nil {
 			return nil, fmt.Errorf("clickhouse module error: %w", err)
 		}
 		outs = append(outs, clickhouse)
 	}
-	return NewLogger(outs...), nil
+	log := NewLogger(outs...)
+	if cfg.Caller {
+		log = log.WithCaller(true)
+	}
+	if cfg.StackLevel != "" {
+		log = log.WithStack(NewLevel(cfg.StackLevel))
+	}
+	return log, nil
 }
 
 var DefaultConfigMinimal = &Config{
@@ -52,3 +87,22 @@ var DefaultConfig = &Config{
 	ForceDebug: false,
 	Clickhouse: DefaultClickhouseConfig,
 }
+
+const secretMask = "***"
+
+// error message with secret values masked
+func redact(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	for _, s := range secrets {
+		if s != "" && strings.Contains(msg, s) {
+			msg = strings.ReplaceAll(msg, s, secretMask)
+		}
+	}
+	if msg == err.Error() {
+		return err
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type ctxKey int
+
+const (
+	loggerCtxKey ctxKey = iota
+	fieldsCtxKey
+)
+
+// context carrying logger
+func WithContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey, l)
+}
+
+var defaultLogger = sync.OnceValue(func() *Logger {
+	return NewLogger()
+})
+
+// logger from context, logger with std output only if context has none
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerCtxKey).(*Logger); ok {
+		return l
+	}
+	return defaultLogger()
+}
+
+// context carrying fields (request id etc) added to records of Ctx methods
+func ContextWithFields(ctx context.Context, fields ...Field) context.Context {
+	return context.WithValue(ctx, fieldsCtxKey, mergeFields(ContextFields(ctx), fields))
+}
+
+func ContextWithParams(ctx context.Context, params ...Param) context.Context {
+	fields := make([]Field, len(params))
+	for i, p := range params {
+		fields[i] = Any(p.Name, p.Value)
+	}
+	return ContextWithFields(ctx, fields...)
+}
+
+func ContextWithParamsMap(ctx context.Context, params map[string]interface{}) context.Context {
+	return ContextWithFields(ctx, Params(params).fields()...)
+}
+
+// fields stored in context
+func ContextFields(ctx context.Context) []Field {
+	fields, _ := ctx.Value(fieldsCtxKey).([]Field)
+	return fields
+}
+
+// params stored in context
+func ContextParams(ctx context.Context) Params {
+	return fieldsParams(ContextFields(ctx))
+}
+
+func (l *BaseLogger) logCtx(ctx context.Context, level Level, s string, a []any) {
+	r := newRecord(level, s, a, l.info)
+	if fields := ContextFields(ctx); len(fields) > 0 {
+		r.Fields = mergeFields(r.Fields, fields)
+	}
+	l.write(r)
+}
+
+func (l *BaseLogger) TraceCtx(ctx context.Context, a ...any) {
+	l.logCtx(ctx, LevelTrace, fmt.Sprint(a...), a)
+}
+func (l *BaseLogger) DebugCtx(ctx context.Context, a ...any) {
+	l.logCtx(ctx, LevelDebug, fmt.Sprint(a...), a)
+}
+func (l *BaseLogger) InfoCtx(ctx context.Context, a ...any) {
+	l.logCtx(ctx, LevelInfo, fmt.Sprint(a...), a)
+}
+func (l *BaseLogger) WarnCtx(ctx context.Context, a ...any) {
+	l.logCtx(ctx, LevelWarn, fmt.Sprint(a...), a)
+}
+func (l *BaseLogger) ErrorCtx(ctx context.Context, a ...any) {
+	l.logCtx(ctx, LevelError, fmt.Sprint(a...), a)
+}
+
+func (l *BaseLogger) TracefCtx(ctx context.Context, format string, a ...any) {
+	l.logCtx(ctx, LevelTrace, fmt.Sprintf(format, a...), a)
+}
+func (l *BaseLogger) DebugfCtx(ctx context.Context, format string, a ...any) {
+	l.logCtx(ctx, LevelDebug, fmt.Sprintf(format, a...), a)
+}
+func (l *BaseLogger) InfofCtx(ctx context.Context, format string, a ...any) {
+	l.logCtx(ctx, LevelInfo, fmt.Sprintf(format, a...), a)
+}
+func (l *BaseLogger) WarnfCtx(ctx context.Context, format string, a ...any) {
+	l.logCtx(ctx, LevelWarn, fmt.Sprintf(format, a...), a)
+}
+func (l *BaseLogger) ErrorfCtx(ctx context.Context, format string, a ...any) {
+	l.logCtx(ctx, LevelError, fmt.Sprintf(format, a...), a)
+}
@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Format string
+
+const (
+	FormatText   Format = "text"   // [LEVEL] (prefix){params} message
+	FormatJSON   Format = "json"   // one json object per line
+	FormatLogfmt Format = "logfmt" // key=value pairs
+)
+
+// record serializer for line based outputs
+type Encoder interface {
+	Encode(r *Record) string
+}
+
+// encoder by format name, text format is used by default
+func NewEncoder(f Format, colored bool) Encoder {
+	switch f {
+	case FormatJSON:
+		return jsonEncoder{}
+	case FormatLogfmt:
+		return logfmtEncoder{}
+	}
+	return textEncoder{colored: colored}
+}
+
+// log.Logger flags for the format, structured formats write own timestamp
+func (f Format) lflags(flags int) int {
+	switch f {
+	case FormatJSON, FormatLogfmt:
+		return 0
+	}
+	return flags
+}
+
+type textEncoder struct {
+	colored bool
+}
+
+func (e textEncoder) Encode(r *Record) string {
+	return format(r, e.colored)
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(r *Record) string {
+	buf := make([]byte, 0, 256)
+	buf = append(buf, `{"ts":`...)
+	buf = r.Time.AppendFormat(append(buf, '"'), time.RFC3339Nano)
+	buf = append(buf, '"')
+	buf = append(buf, `,"level":`...)
+	buf = appendJSONString(buf, r.Level.String())
+	buf = append(buf, `,"prefix":`...)
+	buf = appendJSONString(buf, r.Prefix)
+	if r.Caller != nil {
+		buf = append(buf, `,"caller":`...)
+		buf = appendJSONString(buf, r.Caller.String())
+		buf = append(buf, `,"func":`...)
+		buf = appendJSONString(buf, r.Caller.Function)
+	}
+	for _, f := range r.Fields {
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, f.Key)
+		buf = append(buf, ':')
+		buf = f.appendJSON(buf)
+	}
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, strings.TrimSuffix(r.Message, "\n"))
+	if len(r.Errors) > 0 {
+		buf = append(buf, `,"errors":`...)
+		buf = appendJSON(buf, r.Errors)
+	}
+	if r.Stack != "" {
+		buf = append(buf, `,"stack":`...)
+		buf = appendJSONString(buf, r.Stack)
+	}
+	buf = append(buf, '}')
+	return string(buf)
+}
+
+func appendJSON(buf []byte, v interface{}) []byte {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	res, err := json.Marshal(v)
+	if err != nil {
+		res, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return append(buf, res...)
+}
+
+type logfmtEncoder struct{}
+
+func (logfmtEncoder) Encode(r *Record) string {
+	buf := make([]byte, 0, 256)
+	buf = appendLogfmt(buf, "ts", r.Time.Format(time.RFC3339Nano))
+	buf = appendLogfmt(buf, "level", r.Level.String())
+	buf = appendLogfmt(buf, "prefix", r.Prefix)
+	if r.Caller != nil {
+		buf = appendLogfmt(buf, "caller", r.Caller.String())
+		buf = appendLogfmt(buf, "func", r.Caller.Function)
+	}
+	for _, f := range r.Fields {
+		buf = appendLogfmt(buf, f.Key, f.String())
+	}
+	buf = appendLogfmt(buf, "msg", strings.TrimSuffix(r.Message, "\n"))
+	if len(r.Errors) > 0 {
+		buf = appendLogfmt(buf, "errors", strings.Join(r.Errors, "; "))
+	}
+	if r.Stack != "" {
+		buf = appendLogfmt(buf, "stack", r.Stack)
+	}
+	return string(buf)
+}
+
+func appendLogfmt(buf []byte, key, value string) []byte {
+	if len(buf) > 0 {
+		buf = append(buf, ' ')
+	}
+	buf = appendLogfmtKey(buf, key)
+	buf = append(buf, '=')
+	if strings.ContainsAny(value, " =\"") || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		return strconv.AppendQuote(buf, value)
+	}
+	return append(buf, value...)
+}
+
+// key with spaces, '=', quotes and control characters replaced by '_'
+func appendLogfmtKey(buf []byte, key string) []byte {
+	if key == "" {
+		return append(buf, '_')
+	}
+	for _, c := range key {
+		if c == ' ' || c == '=' || c == '"' || unicode.IsControl(c) || c == utf8.RuneError {
+			c = '_'
+		}
+		buf = utf8.AppendRune(buf, c)
+	}
+	return buf
+}
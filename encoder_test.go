@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAppendLogfmt(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       string
+	}{
+		{"k", "plain", `k=plain`},
+		{"k", "", `k=`},
+		{"k", "two words", `k="two words"`},
+		{"k", "a=b", `k="a=b"`},
+		{"k", `say "hi"`, `k="say \"hi\""`},
+		{"k", "line\nbreak", `k="line\nbreak"`},
+		{"k", "tab\there", `k="tab\there"`},
+		{"k", "юникод", `k=юникод`},
+		{"user id", "1", `user_id=1`},
+		{"a=b", "1", `a_b=1`},
+		{`"q"`, "1", `_q_=1`},
+		{"new\nline", "1", `new_line=1`},
+		{"", "1", `_=1`},
+	}
+	for _, tt := range tests {
+		if got := string(appendLogfmt(nil, tt.key, tt.value)); got != tt.want {
+			t.Errorf("%q=%q: got %s, want %s", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func testRecord() *Record {
+	return &Record{
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC),
+		Level:   LevelError,
+		Prefix:  "app",
+		Fields:  []Field{Int("count", 3), String("user name", "bob smith"), Duration("took", time.Second)},
+		Message: "failed\n",
+		Caller:  &Caller{File: "/src/app/main.go", Line: 10, Function: "main.main"},
+		Stack:   "main.main()\n\t/src/app/main.go:10",
+		Errors:  []string{"outer: inner", "inner"},
+	}
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+	got := NewEncoder(FormatLogfmt, false).Encode(testRecord())
+	want := `ts=2024-01-02T03:04:05.0000006Z level=error prefix=app caller=app/main.go:10 func=main.main ` +
+		`count=3 user_name="bob smith" took=1s msg=failed errors="outer: inner; inner" stack="main.main()\n\t/src/app/main.go:10"`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	line := NewEncoder(FormatJSON, false).Encode(testRecord())
+	var got map[string]any
+	if err := json.Unmarshal([]byte(line), &got); err != nil {
+		t.Fatalf("invalid json %s: %v", line, err)
+	}
+	want := map[string]any{
+		"ts":        "2024-01-02T03:04:05.0000006Z",
+		"level":     "error",
+		"prefix":    "app",
+		"caller":    "app/main.go:10",
+		"func":      "main.main",
+		"count":     float64(3),
+		"user name": "bob smith",
+		"took":      float64(time.Second),
+		"msg":       "failed",
+		"errors":    []any{"outer: inner", "inner"},
+		"stack":     "main.main()\n\t/src/app/main.go:10",
+	}
+	g, _ := json.Marshal(got)
+	w, _ := json.Marshal(want)
+	if string(g) != string(w) {
+		t.Errorf("got  %s\nwant %s", g, w)
+	}
+}
+
+func TestJSONEncoderOptionalFields(t *testing.T) {
+	r := &Record{Time: time.Unix(0, 0).UTC(), Level: LevelInfo, Message: "hi",
+		Fields: []Field{Err(errors.New("boom")), Any("list", []int{1, 2})}}
+	got := NewEncoder(FormatJSON, false).Encode(r)
+	want := `{"ts":"1970-01-01T00:00:00Z","level":"info","prefix":"","error":"boom","list":[1,2],"msg":"hi"}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
@@ -26,6 +26,9 @@ func main() {
 	withParams.Infoln("Hello with params")
 	withParams.Params(logger.Param{"some", "hello"}).Infoln("Hello with params 2")
 
+	// типизированные поля, без копирования map и reflection при записи
+	sublog.With(logger.String("user", "bob"), logger.Int("id", 1)).Infoln("Hello with fields")
+
 	// можно использовать конкретный модуль
 	sublog.Get("clickhouse").Warnln("what happend???")
 	subsublog.Std().Debugln("here")
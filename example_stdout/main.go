@@ -27,8 +27,8 @@ func main() {
 	subsublog.Std().Debugln("here")
 	subsublog.Params(logger.Param{"stack", string(debug.Stack())}).Tranceln("deep debug")
 
-	subsublog.Fatalln("AAAAAAA")
 	sublog.Warnln("OH... OK")
+	subsublog.Fatalln("AAAAAAA") // flushes and closes outputs, then exits with code 1
 }
 
 func initLogger() *logger.Logger {
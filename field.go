@@ -0,0 +1,252 @@
+package logger
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+type fieldKind uint8
+
+const (
+	anyField fieldKind = iota
+	stringField
+	intField
+	floatField
+	boolField
+	durationField
+	timeField
+	errorField
+)
+
+// typed record field, encoded by outputs without reflection
+type Field struct {
+	Key   string
+	kind  fieldKind
+	num   int64
+	str   string
+	iface any
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, kind: stringField, str: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, kind: intField, num: int64(value)}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, kind: intField, num: value}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: floatField, num: int64(math.Float64bits(value))}
+}
+
+func Bool(key string, value bool) Field {
+	var num int64
+	if value {
+		num = 1
+	}
+	return Field{Key: key, kind: boolField, num: num}
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: durationField, num: int64(value)}
+}
+
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, kind: timeField, iface: value}
+}
+
+// error field with "error" key
+func Err(err error) Field {
+	return NamedErr("error", err)
+}
+
+func NamedErr(key string, err error) Field {
+	if err == nil {
+		return Field{Key: key, kind: anyField}
+	}
+	return Field{Key: key, kind: errorField, iface: err}
+}
+
+// field of any type, encoded with encoding/json
+func Any(key string, value any) Field {
+	switch v := value.(type) {
+	case string:
+		return String(key, v)
+	case int:
+		return Int(key, v)
+	case int64:
+		return Int64(key, v)
+	case float64:
+		return Float64(key, v)
+	case bool:
+		return Bool(key, v)
+	case time.Duration:
+		return Duration(key, v)
+	case time.Time:
+		return Time(key, v)
+	case error:
+		return NamedErr(key, v)
+	}
+	return Field{Key: key, kind: anyField, iface: value}
+}
+
+// field value as go type
+func (f Field) Value() any {
+	switch f.kind {
+	case stringField:
+		return f.str
+	case intField:
+		return f.num
+	case floatField:
+		return math.Float64frombits(uint64(f.num))
+	case boolField:
+		return f.num == 1
+	case durationField:
+		return time.Duration(f.num)
+	}
+	return f.iface
+}
+
+// text form of the value
+func (f Field) String() string {
+	switch f.kind {
+	case stringField:
+		return f.str
+	case intField:
+		return strconv.FormatInt(f.num, 10)
+	case floatField:
+		return strconv.FormatFloat(math.Float64frombits(uint64(f.num)), 'g', -1, 64)
+	case boolField:
+		return strconv.FormatBool(f.num == 1)
+	case durationField:
+		return time.Duration(f.num).String()
+	case timeField:
+		return f.iface.(time.Time).Format(time.RFC3339Nano)
+	case errorField:
+		return f.iface.(error).Error()
+	}
+	return fmt.Sprint(f.iface)
+}
+
+func (f Field) appendJSON(buf []byte) []byte {
+	switch f.kind {
+	case intField, durationField: // durations as nanoseconds like encoding/json
+		return strconv.AppendInt(buf, f.num, 10)
+	case floatField:
+		v := math.Float64frombits(uint64(f.num))
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return appendJSONString(buf, f.String())
+		}
+		return strconv.AppendFloat(buf, v, 'g', -1, 64)
+	case boolField:
+		return strconv.AppendBool(buf, f.num == 1)
+	case anyField:
+		return appendJSON(buf, f.iface)
+	}
+	return appendJSONString(buf, f.String())
+}
+
+// json object of fields, empty string if there are no fields
+func fieldsJSON(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '{')
+	for i, f := range fields {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, f.Key)
+		buf = append(buf, ':')
+		buf = f.appendJSON(buf)
+	}
+	buf = append(buf, '}')
+	return string(buf)
+}
+
+// fields as params, later fields override earlier ones with the same key
+func fieldsParams(fields []Field) Params {
+	params := make(Params, len(fields))
+	for _, f := range fields {
+		params[f.Key] = f.Value()
+	}
+	return params
+}
+
+// params as fields sorted by key
+func (params Params) fields() []Field {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]Field, len(keys))
+	for i, k := range keys {
+		fields[i] = Any(k, params[k])
+	}
+	return fields
+}
+
+// fields with appended ones, replaced keys are removed from the parent fields
+func mergeFields(fields, other []Field) []Field {
+	res := make([]Field, 0, len(fields)+len(other))
+	for _, f := range fields {
+		if !hasField(other, f.Key) {
+			res = append(res, f)
+		}
+	}
+	return append(res, other...)
+}
+
+func hasField(fields []Field, key string) bool {
+	for _, f := range fields {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+const hex = "0123456789abcdef"
+
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf = append(buf, '\\', c)
+			case c == '\n':
+				buf = append(buf, '\\', 'n')
+			case c == '\r':
+				buf = append(buf, '\\', 'r')
+			case c == '\t':
+				buf = append(buf, '\\', 't')
+			case c < 0x20:
+				buf = append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			default:
+				buf = append(buf, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, `�`...)
+		} else {
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(buf, '"')
+}
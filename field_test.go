@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFieldsJSONMatchesParams(t *testing.T) {
+	params := Params{
+		"duration": 1500 * time.Millisecond,
+		"float":    1.5,
+		"int":      42,
+		"bool":     true,
+		"string":   "a \"quoted\" value",
+		"slice":    []int{1, 2},
+		"time":     time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+	}
+	var got, want map[string]any
+	if err := json.Unmarshal([]byte(fieldsJSON(params.fields())), &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(params.Json()), &want); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range want {
+		g, _ := json.Marshal(got[k])
+		w, _ := json.Marshal(v)
+		if string(g) != string(w) {
+			t.Errorf("%s: got %s, want %s", k, g, w)
+		}
+	}
+}
+
+func TestTimeFieldOutsideUnixNanoRange(t *testing.T) {
+	for _, tm := range []time.Time{
+		{},
+		time.Date(1500, 6, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2300, 6, 1, 12, 0, 0, 5, time.FixedZone("X", 3600)),
+	} {
+		f := Time("time", tm)
+		if got := f.Value().(time.Time); !got.Equal(tm) {
+			t.Errorf("value %v, want %v", got, tm)
+		}
+		if got, want := f.String(), tm.Format(time.RFC3339Nano); got != want {
+			t.Errorf("string %q, want %q", got, want)
+		}
+		if got, want := fieldsJSON([]Field{f}), (Params{"time": tm}).Json(); got != want {
+			t.Errorf("json %s, want %s", got, want)
+		}
+	}
+}
@@ -4,33 +4,50 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type FileOutConfig struct {
-	Enabled  bool   `json:"enabled" yaml:"enabled"`
-	FilePath string `json:"filePath" yaml:"filePath"`
-	LFlags   int    `json:"lflags" yaml:"lflags"`
+	Name       string `json:"name" yaml:"name"` // instance name, "file" by default
+	Enabled    bool   `json:"enabled" yaml:"enabled"`
+	FilePath   string `json:"filePath" yaml:"filePath"`
+	LFlags     int    `json:"lflags" yaml:"lflags"`
+	Format     Format `json:"format" yaml:"format"`
+	LogLevel   Level  `json:"logLevel" yaml:"logLevel"`
+	ForceDebug bool   `json:"forceDebug" yaml:"forceDebug"`
+	// rotation, disabled by default
+	MaxSize    int64         `json:"maxSize" yaml:"maxSize"`       // max file size in bytes
+	Rotate     string        `json:"rotate" yaml:"rotate"`         // "hourly" or "daily"
+	MaxBackups int           `json:"maxBackups" yaml:"maxBackups"` // rotated files to keep, 0 - all
+	MaxAge     time.Duration `json:"maxAge" yaml:"maxAge"`         // max age of rotated files, 0 - forever
+	Compress   bool          `json:"compress" yaml:"compress"`     // gzip rotated files
 }
 
 var DefaultFileOutConfig = &FileOutConfig{
 	Enabled:  true,
 	FilePath: filepath.Base(os.Args[0]) + ".log",
 	LFlags:   log.LstdFlags,
+	Format:   FormatText,
 }
 
 type FileOut struct {
-	file *os.File
-	l    *log.Logger
+	cfg   *FileOutConfig
+	file  *fileWriter
+	l     *log.Logger
+	enc   Encoder
+	stats outStats
 }
 
 func NewFileOut(cfg *FileOutConfig) (*FileOut, error) {
-	file, err := os.OpenFile(cfg.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	file, err := newFileWriter(cfg)
 	if err != nil {
 		return nil, err
 	}
 	return &FileOut{
+		cfg:  cfg,
 		file: file,
-		l:    log.New(file, "", cfg.LFlags),
+		l:    log.New(file, "", cfg.Format.lflags(cfg.LFlags)),
+		enc:  NewEncoder(cfg.Format, false),
 	}, nil
 }
 
@@ -38,16 +55,33 @@ func (l *FileOut) Close() error {
 	return l.file.Close()
 }
 
-func (l *FileOut) log(level Level, s string, i *info) {
-	l.l.Print(format(level, false, s, i))
+// reopen log file, use it after external rotation (logrotate)
+func (l *FileOut) Reopen() error {
+	return l.file.Reopen()
 }
 
-func (l *FileOut) init(main *Logger) {
+func (l *FileOut) Log(r *Record) {
+	if !r.Level.allowed(l.cfg.LogLevel, l.cfg.ForceDebug, false) {
+		return
+	}
+	l.stats.write(l.l.Output(2, l.enc.Encode(r)))
+}
+
+func (l *FileOut) Stats() OutStats {
+	s := l.stats.snapshot()
+	s.Name = l.Name()
+	return s
 }
 
-func (l *FileOut) name() string {
+func (l *FileOut) Init(main *Logger) {
+}
+
+func (l *FileOut) Name() string {
+	if l.cfg.Name != "" {
+		return l.cfg.Name
+	}
 	return "file"
 }
 
-func (l *FileOut) flush() {
+func (l *FileOut) Flush() {
 }
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileOutLevelFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	out, err := NewFileOut(&FileOutConfig{FilePath: path, LogLevel: LevelInfo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewLogger(out, NewStdOut(&StdOutConfig{LogLevel: LevelPanic}))
+	log.Print("print")
+	log.Debug("debug")
+	log.Info("info")
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readFile(t, path)
+	for _, s := range []string{"print", "info"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("%q record is filtered out", s)
+		}
+	}
+	if strings.Contains(got, "debug") {
+		t.Error("debug record is written")
+	}
+}
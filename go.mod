@@ -1,6 +1,6 @@
 module github.com/nikulex/logger
 
-go 1.20
+go 1.21
 
 require (
 	github.com/ClickHouse/clickhouse-go v1.5.1
@@ -12,6 +12,7 @@ const (
 	LevelWarn
 	LevelError
 	LevelFatal
+	LevelPanic
 )
 
 func NewLevel(name string) Level {
@@ -28,10 +29,22 @@ func NewLevel(name string) Level {
 		return LevelError
 	case "emerg", "fatal", "alert", "crit", "critical":
 		return LevelFatal
+	case "panic":
+		return LevelPanic
 	}
 	return LevelDebug // default
 }
 
+// level filter, debug records also pass when forceDebug is set.
+// Records without level (Print) always pass, with filterPrint (std output)
+// only if there is no minimal level.
+func (l Level) allowed(min Level, forceDebug, filterPrint bool) bool {
+	if l == LevelUnknown {
+		return !filterPrint || min == LevelUnknown
+	}
+	return l >= min || (l == LevelDebug && forceDebug)
+}
+
 func (l Level) String() string {
 	switch l {
 	case LevelTrace:
@@ -46,6 +59,8 @@ func (l Level) String() string {
 		return "error"
 	case LevelFatal:
 		return "fatal"
+	case LevelPanic:
+		return "panic"
 	}
 	return ""
 }
@@ -64,6 +79,8 @@ func (l Level) Prefix() string {
 		return "ERROR"
 	case LevelFatal:
 		return "FATAL"
+	case LevelPanic:
+		return "PANIC"
 	}
 	return ""
 }
@@ -82,6 +99,8 @@ func (l Level) PrefixColor() string {
 		return "\x1b[1;31mERROR\x1b[0m"
 	case LevelFatal:
 		return "\x1b[1;35mFATAL\x1b[0m"
+	case LevelPanic:
+		return "\x1b[1;41mPANIC\x1b[0m"
 	}
 	return ""
 }
@@ -0,0 +1,29 @@
+package logger
+
+import "testing"
+
+func TestLevelAllowed(t *testing.T) {
+	tests := []struct {
+		level       Level
+		min         Level
+		forceDebug  bool
+		filterPrint bool
+		want        bool
+	}{
+		{LevelInfo, LevelInfo, false, false, true},
+		{LevelWarn, LevelInfo, false, false, true},
+		{LevelDebug, LevelInfo, false, false, false},
+		{LevelDebug, LevelInfo, true, false, true},
+		{LevelTrace, LevelInfo, true, false, false},
+		{LevelTrace, LevelUnknown, false, false, true},
+		{LevelUnknown, LevelPanic, false, false, true},
+		{LevelUnknown, LevelInfo, false, true, false},
+		{LevelUnknown, LevelUnknown, false, true, true},
+	}
+	for _, tt := range tests {
+		if got := tt.level.allowed(tt.min, tt.forceDebug, tt.filterPrint); got != tt.want {
+			t.Errorf("%v allowed by %v (forceDebug %v, filterPrint %v): %v, want %v",
+				tt.level, tt.min, tt.forceDebug, tt.filterPrint, got, tt.want)
+		}
+	}
+}
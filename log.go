@@ -1,136 +1,299 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 )
 
 type BaseLogger struct {
 	internal
 	*info
+	core *core
 }
 
 func (l *BaseLogger) Print(a ...any) {
-	l.log(LevelUnknown, fmt.Sprint(a...), l.info)
+	l.log(LevelUnknown, fmt.Sprint(a...), a)
 }
 func (l *BaseLogger) Trace(a ...any) {
-	l.log(LevelTrace, fmt.Sprint(a...), l.info)
+	l.log(LevelTrace, fmt.Sprint(a...), a)
 }
 func (l *BaseLogger) Debug(a ...any) {
-	l.log(LevelDebug, fmt.Sprint(a...), l.info)
+	l.log(LevelDebug, fmt.Sprint(a...), a)
 }
 func (l *BaseLogger) Info(a ...any) {
-	l.log(LevelInfo, fmt.Sprint(a...), l.info)
+	l.log(LevelInfo, fmt.Sprint(a...), a)
 }
 func (l *BaseLogger) Warn(a ...any) {
-	l.log(LevelWarn, fmt.Sprint(a...), l.info)
+	l.log(LevelWarn, fmt.Sprint(a...), a)
 }
 func (l *BaseLogger) Error(a ...any) {
-	l.log(LevelError, fmt.Sprint(a...), l.info)
+	l.log(LevelError, fmt.Sprint(a...), a)
 }
 func (l *BaseLogger) Fatal(a ...any) {
-	l.log(LevelFatal, fmt.Sprint(a...), l.info)
+	l.log(LevelFatal, fmt.Sprint(a...), a)
+	l.core.exit()
+}
+
+// log at panic level, flush outputs and panic with the message
+func (l *BaseLogger) Panic(a ...any) {
+	l.panic(fmt.Sprint(a...), a)
 }
 
 func (l *BaseLogger) Printf(format string, a ...any) {
-	l.log(LevelUnknown, fmt.Sprintf(format, a...), l.info)
+	l.log(LevelUnknown, fmt.Sprintf(format, a...), a)
 }
 func (l *BaseLogger) Tracef(format string, a ...any) {
-	l.log(LevelTrace, fmt.Sprintf(format, a...), l.info)
+	l.log(LevelTrace, fmt.Sprintf(format, a...), a)
 }
 func (l *BaseLogger) Debugf(format string, a ...any) {
-	l.log(LevelDebug, fmt.Sprintf(format, a...), l.info)
+	l.log(LevelDebug, fmt.Sprintf(format, a...), a)
 }
 func (l *BaseLogger) Infof(format string, a ...any) {
-	l.log(LevelInfo, fmt.Sprintf(format, a...), l.info)
+	l.log(LevelInfo, fmt.Sprintf(format, a...), a)
 }
 func (l *BaseLogger) Warnf(format string, a ...any) {
-	l.log(LevelWarn, fmt.Sprintf(format, a...), l.info)
+	l.log(LevelWarn, fmt.Sprintf(format, a...), a)
 }
 func (l *BaseLogger) Errorf(format string, a ...any) {
-	l.log(LevelError, fmt.Sprintf(format, a...), l.info)
+	l.log(LevelError, fmt.Sprintf(format, a...), a)
 }
 func (l *BaseLogger) Fatalf(format string, a ...any) {
-	l.log(LevelFatal, fmt.Sprintf(format, a...), l.info)
+	l.log(LevelFatal, fmt.Sprintf(format, a...), a)
+	l.core.exit()
+}
+
+func (l *BaseLogger) Panicf(format string, a ...any) {
+	l.panic(fmt.Sprintf(format, a...), a)
 }
 
 func (l *BaseLogger) Println(a ...any) {
-	l.log(LevelUnknown, fmt.Sprintln(a...), l.info)
+	l.log(LevelUnknown, fmt.Sprintln(a...), a)
 }
 func (l *BaseLogger) Tranceln(a ...any) {
-	l.log(LevelTrace, fmt.Sprintln(a...), l.info)
+	l.log(LevelTrace, fmt.Sprintln(a...), a)
 }
 func (l *BaseLogger) Debugln(a ...any) {
-	l.log(LevelDebug, fmt.Sprintln(a...), l.info)
+	l.log(LevelDebug, fmt.Sprintln(a...), a)
 }
 func (l *BaseLogger) Infoln(a ...any) {
-	l.log(LevelInfo, fmt.Sprintln(a...), l.info)
+	l.log(LevelInfo, fmt.Sprintln(a...), a)
 }
 func (l *BaseLogger) Warnln(a ...any) {
-	l.log(LevelWarn, fmt.Sprintln(a...), l.info)
+	l.log(LevelWarn, fmt.Sprintln(a...), a)
 }
 func (l *BaseLogger) Errorln(a ...any) {
-	l.log(LevelError, fmt.Sprintln(a...), l.info)
+	l.log(LevelError, fmt.Sprintln(a...), a)
 }
 func (l *BaseLogger) Fatalln(a ...any) {
-	l.log(LevelFatal, fmt.Sprintln(a...), l.info)
+	l.log(LevelFatal, fmt.Sprintln(a...), a)
+	l.core.exit()
 }
 
-type loggerOuts map[string]LoggerOut
+func (l *BaseLogger) Panicln(a ...any) {
+	l.panic(fmt.Sprintln(a...), a)
+}
 
-func (outs loggerOuts) log(l Level, s string, i *info) {
-	for _, out := range outs {
-		out.log(l, s, i)
+func (l *BaseLogger) panic(s string, a []any) {
+	l.log(LevelPanic, s, a)
+	l.core.flush()
+	panic(s)
+}
+
+func (l *BaseLogger) log(level Level, s string, a []any) {
+	l.write(newRecord(level, s, a, l.info))
+}
+
+// registered output with unique instance name
+type namedOut struct {
+	name string
+	out  LoggerOut
+}
+
+// outputs in registration order, copy on write so logging never waits for changes
+type loggerOuts struct {
+	mu   sync.Mutex
+	list atomic.Pointer[[]namedOut]
+}
+
+func (outs *loggerOuts) all() []namedOut {
+	if list := outs.list.Load(); list != nil {
+		return *list
+	}
+	return nil
+}
+
+func (outs *loggerOuts) write(r *Record) {
+	for _, o := range outs.all() {
+		o.out.Log(r)
 	}
 }
 
+func (outs *loggerOuts) get(name string) LoggerOut {
+	for _, o := range outs.all() {
+		if o.name == name {
+			return o.out
+		}
+	}
+	return nil
+}
+
+// append output, false if the name is taken
+func (outs *loggerOuts) add(name string, out LoggerOut) bool {
+	outs.mu.Lock()
+	defer outs.mu.Unlock()
+	if outs.get(name) != nil {
+		return false
+	}
+	old := outs.all()
+	list := append(old[:len(old):len(old)], namedOut{name, out})
+	outs.list.Store(&list)
+	return true
+}
+
+// append output, name-2, name-3 etc are used if the name is taken
+func (outs *loggerOuts) addUnique(out LoggerOut) {
+	name := out.Name()
+	for i := 2; !outs.add(name, out); i++ {
+		name = fmt.Sprintf("%s-%d", out.Name(), i)
+	}
+}
+
+func (outs *loggerOuts) remove(name string) LoggerOut {
+	outs.mu.Lock()
+	defer outs.mu.Unlock()
+	old := outs.all()
+	list := make([]namedOut, 0, len(old))
+	var removed LoggerOut
+	for _, o := range old {
+		if removed == nil && o.name == name {
+			removed = o.out
+			continue
+		}
+		list = append(list, o)
+	}
+	outs.list.Store(&list)
+	return removed
+}
+
+// writes records to a single output
+type singleOut struct {
+	LoggerOut
+}
+
+func (o singleOut) write(r *Record) {
+	o.Log(r)
+}
+
+// state shared by a logger and all its subloggers
+type core struct {
+	outs     *loggerOuts
+	exitCode int
+	exitFunc func(code int)
+}
+
+func (c *core) flush() {
+	for _, o := range c.outs.all() {
+		o.out.Flush()
+	}
+}
+
+// close all outputs, errors are joined
+func (c *core) close() error {
+	var errs []error
+	for _, o := range c.outs.all() {
+		if err := o.out.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close %s error: %w", o.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flush and close outputs before exit
+func (c *core) exit() {
+	c.flush()
+	if err := c.close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	c.exitFunc(c.exitCode)
+}
+
 type Logger struct {
 	BaseLogger
-	outs loggerOuts
 }
 
+// logger with outputs, outputs with the same name get -2, -3 etc suffixes
 func NewLogger(outs ...LoggerOut) *Logger {
-	louts := make(loggerOuts)
+	c := &core{
+		outs:     &loggerOuts{},
+		exitCode: 1,
+		exitFunc: os.Exit,
+	}
 	main := &Logger{
-		BaseLogger: BaseLogger{louts, &info{}},
-		outs:       louts,
+		BaseLogger: BaseLogger{c.outs, &info{}, c},
 	}
 	for _, out := range outs {
-		out.init(main)
-		main.outs[out.name()] = out
+		c.outs.addUnique(out)
 	}
 	// required stdout logger
-	if _, ok := main.outs["std"]; !ok {
+	if c.outs.get("std") == nil {
 		out := NewStdOut(nil)
-		out.init(main)
-		main.outs[out.name()] = out
+		c.outs.add(out.Name(), out)
+	}
+	// outputs are initialized once std is registered, they may log to it
+	for _, o := range c.outs.all() {
+		o.out.Init(main)
 	}
 	return main
 }
 
-func (l *Logger) Close() error {
-	for _, out := range l.outs {
-		if err := out.Close(); err != nil {
-			return fmt.Errorf("close main logger error: %w", err)
-		}
+// register output at runtime, safe for concurrent logging
+func (l *Logger) AddOut(out LoggerOut) error {
+	if l.core.outs.get(out.Name()) != nil {
+		return fmt.Errorf("output %s already exists", out.Name())
+	}
+	out.Init(l)
+	if !l.core.outs.add(out.Name(), out) {
+		return fmt.Errorf("output %s already exists", out.Name())
 	}
 	return nil
 }
 
+// unregister output at runtime and return it, the output is not closed;
+// std output can't be removed
+func (l *Logger) RemoveOut(name string) LoggerOut {
+	if name == "std" {
+		return nil
+	}
+	return l.core.outs.remove(name)
+}
+
+func (l *Logger) Close() error {
+	return l.core.close()
+}
+
+// exit code of Fatal methods, 1 by default, shared with subloggers
+func (l *Logger) SetExitCode(code int) {
+	l.core.exitCode = code
+}
+
+// replace os.Exit called by Fatal methods, e.g. to prevent exit in tests
+func (l *Logger) SetExitFunc(exit func(code int)) {
+	l.core.exitFunc = exit
+}
+
 // sublogger with prefix
 func (l *Logger) New(name string) *Logger {
 	if l.prefix != "" {
 		name = l.prefix + "/" + name // submodules path
 	}
-	return &Logger{
-		BaseLogger: BaseLogger{
-			internal: l.internal,
-			info: &info{
-				params: l.params,
-				prefix: name,
-			},
-		},
-		outs: l.outs,
-	}
+	i := *l.info
+	i.prefix = name
+	return l.child(&i)
 }
 
 type Param struct {
@@ -139,44 +302,129 @@ type Param struct {
 }
 
 func (l *Logger) Params(params ...Param) *Logger {
-	paramsMap := make(Params)
-	for _, p := range params {
-		paramsMap[p.Name] = p.Value
+	fields := make([]Field, len(params))
+	for i, p := range params {
+		fields[i] = Any(p.Name, p.Value)
 	}
-	return l.ParamsMap(paramsMap)
+	i := *l.info
+	i.fields = mergeFields(l.fields, fields)
+	return l.child(&i)
 }
 
 // sublogger with params
 func (l *Logger) ParamsMap(params map[string]interface{}) *Logger {
-	child := &Logger{
+	i := *l.info
+	i.fields = mergeFields(l.fields, Params(params).fields())
+	return l.child(&i)
+}
+
+// sublogger with typed fields, cheaper than Params: parent fields are not copied
+// and duplicate keys are not replaced
+func (l *Logger) With(fields ...Field) *Logger {
+	i := *l.info
+	i.fields = append(l.fields[:len(l.fields):len(l.fields)], fields...)
+	return l.child(&i)
+}
+
+// sublogger attaching goroutine stack and error chains to records at level and above,
+// LevelUnknown disables it
+func (l *Logger) WithStack(level Level) *Logger {
+	i := *l.info
+	i.stackLevel = level
+	return l.child(&i)
+}
+
+// sublogger capturing caller file, line and function of each record
+func (l *Logger) WithCaller(enabled bool) *Logger {
+	i := *l.info
+	i.caller = enabled
+	return l.child(&i)
+}
+
+// sublogger skipping extra caller frames, for wrappers around the logger
+func (l *Logger) CallerSkip(skip int) *Logger {
+	i := *l.info
+	i.callerSkip += skip
+	return l.child(&i)
+}
+
+func (l *Logger) child(i *info) *Logger {
+	return &Logger{
 		BaseLogger: BaseLogger{
 			internal: l.internal,
-			info: &info{
-				params: make(Params, len(l.params)+len(params)),
-				prefix: l.prefix,
-			},
+			info:     i,
+			core:     l.core,
 		},
-		outs: l.outs,
 	}
-	for k, v := range l.params {
-		child.params[k] = v
+}
+
+func (l *Logger) Flush() {
+	l.core.flush()
+}
+
+// use with defer, logs recovered panic with stack trace and flushes outputs,
+// repanic continues panicking with the recovered value
+func (l *Logger) Recover(repanic bool) {
+	if r := recover(); r != nil {
+		rec := newRecord(LevelPanic, fmt.Sprintf("recovered panic: %v", r), []any{r}, l.info)
+		if rec.Stack == "" {
+			rec.Stack = captureStack()
+			rec.Errors = errorChain([]any{r})
+		}
+		l.write(rec)
+		l.Flush()
+		if repanic {
+			panic(r)
+		}
 	}
-	for k, v := range params {
-		child.params[k] = v
+}
+
+// reopen files of all outputs which support it
+func (l *Logger) Reopen() error {
+	var errs []error
+	for _, o := range l.core.outs.all() {
+		if r, ok := o.out.(Reopener); ok {
+			if err := r.Reopen(); err != nil {
+				errs = append(errs, fmt.Errorf("reopen %s error: %w", o.name, err))
+			}
+		}
 	}
-	return child
+	return errors.Join(errs...)
 }
 
-func (l *Logger) Flush() {
-	for _, out := range l.outs {
-		out.flush()
+// reopen outputs on signal (SIGHUP by default), call stop to disable handler
+func (l *Logger) ReopenOnSignal(sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sig...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := l.Reopen(); err != nil {
+					l.Std().Errorf("reopen outputs error: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
 	}
 }
 
-// access to specific module with same interface
+// access to specific module with same interface, by instance name
 func (l *Logger) Get(out string) *BaseLogger {
-	if o, ok := l.outs[out]; ok {
-		return &BaseLogger{o, l.info}
+	if o := l.core.outs.get(out); o != nil {
+		return &BaseLogger{singleOut{o}, l.info, l.core}
 	}
 	return nil
 }
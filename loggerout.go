@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
 type Params map[string]interface{}
@@ -17,50 +19,114 @@ func (params Params) Json() string {
 }
 
 type info struct {
-	params Params
-	prefix string
+	fields     []Field
+	prefix     string
+	caller     bool
+	callerSkip int
+	stackLevel Level // LevelUnknown - disabled
 }
 
-func format(l Level, colored bool, s string, i *info) string {
+// single log entry passed to outputs, must be treated as read-only
+type Record struct {
+	Time    time.Time
+	Level   Level
+	Prefix  string
+	Fields  []Field
+	Message string
+	Caller  *Caller  // nil if caller capture is disabled
+	Stack   string   // goroutine stack if enabled for the level
+	Errors  []string // messages of error arguments and errors wrapped by them
+}
+
+func newRecord(l Level, s string, a []any, i *info) *Record {
+	r := &Record{
+		Time:    time.Now(),
+		Level:   l,
+		Prefix:  i.prefix,
+		Fields:  i.fields,
+		Message: s,
+	}
+	if i.caller {
+		r.Caller = captureCaller(i.callerSkip)
+	}
+	if i.stackLevel != LevelUnknown && l >= i.stackLevel {
+		r.Stack = captureStack()
+		r.Errors = errorChain(a)
+	}
+	return r
+}
+
+// record fields as params map
+func (r *Record) Params() Params {
+	return fieldsParams(r.Fields)
+}
+
+func format(r *Record, colored bool) string {
 	var prefix, params string
-	if len(i.prefix) > 0 {
+	if len(r.Prefix) > 0 {
 		if colored {
-			prefix = fmt.Sprintf("\x1b[0;30m (%s)\x1b[0m", i.prefix)
+			prefix = fmt.Sprintf("\x1b[0;30m (%s)\x1b[0m", r.Prefix)
 		} else {
-			prefix = fmt.Sprintf(" (%s)", i.prefix)
+			prefix = fmt.Sprintf(" (%s)", r.Prefix)
 		}
 	}
-	if len(i.params) > 0 {
+	if r.Caller != nil {
 		if colored {
-			params = "\x1b[0;36m" + i.params.Json() + "\x1b[0m"
+			prefix += "\x1b[0;90m " + r.Caller.String() + "\x1b[0m"
 		} else {
-			params = i.params.Json()
+			prefix += " " + r.Caller.String()
+		}
+	}
+	if len(r.Fields) > 0 {
+		if colored {
+			params = "\x1b[0;36m" + fieldsJSON(r.Fields) + "\x1b[0m"
+		} else {
+			params = fieldsJSON(r.Fields)
 		}
 	}
 	var loglevel string
 	if colored {
-		loglevel = l.PrefixColor()
+		loglevel = r.Level.PrefixColor()
 	} else {
-		loglevel = l.Prefix()
+		loglevel = r.Level.Prefix()
 	}
-	if l != LevelUnknown {
+	if r.Level != LevelUnknown {
 		loglevel = "[" + loglevel + "]"
 	}
-	if l == LevelInfo || l == LevelWarn {
+	if r.Level == LevelInfo || r.Level == LevelWarn {
 		loglevel += " "
 	}
-	return fmt.Sprintf("%s%s%v %s", loglevel, prefix, params, s)
+	msg := fmt.Sprintf("%s%s%v %s", loglevel, prefix, params, r.Message)
+	if len(r.Errors) > 0 || r.Stack != "" {
+		msg = strings.TrimSuffix(msg, "\n")
+		for _, e := range r.Errors {
+			msg += "\n\terror: " + e
+		}
+		if r.Stack != "" {
+			msg += "\n" + r.Stack
+		}
+	}
+	return msg
 }
 
 type internal interface {
-	log(l Level, s string, i *info)
+	write(r *Record)
 }
 
-// logger module interface
+// logger module interface, implement it to add custom outputs
 type LoggerOut interface {
-	internal
 	io.Closer
-	init(l *Logger)
-	name() string
-	flush()
+	// called once when the output is registered in a logger
+	Init(l *Logger)
+	// instance name used by Logger.Get
+	Name() string
+	// write a single record
+	Log(r *Record)
+	// write buffered records, if any
+	Flush()
+}
+
+// optional output interface, reopens underlying files
+type Reopener interface {
+	Reopen() error
 }
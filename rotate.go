@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RotateNone   = ""
+	RotateHourly = "hourly"
+	RotateDaily  = "daily"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// log file writer with size and time based rotation
+type fileWriter struct {
+	cfg    *FileOutConfig
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time // start of the current rotation period
+	millMu sync.Mutex
+	millWg sync.WaitGroup // backup processing, waited by Close
+}
+
+func newFileWriter(cfg *FileOutConfig) (*fileWriter, error) {
+	w := &fileWriter{cfg: cfg}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *fileWriter) open() error {
+	file, size, period, err := w.openFile()
+	if err != nil {
+		return err
+	}
+	w.file, w.size, w.period = file, size, period
+	return nil
+}
+
+// open file by path with its size and rotation period, current file is not changed
+func (w *fileWriter) openFile() (*os.File, int64, time.Time, error) {
+	file, err := os.OpenFile(w.cfg.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, 0, time.Time{}, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, time.Time{}, err
+	}
+	period := w.periodStart(time.Now())
+	if stat.Size() > 0 {
+		period = w.periodStart(stat.ModTime())
+	}
+	return file, stat.Size(), period, nil
+}
+
+// replace current file with the one opened by path, must be called with w.mu held
+func (w *fileWriter) swap() error {
+	file, size, period, err := w.openFile()
+	if err != nil {
+		return err
+	}
+	old := w.file
+	w.file, w.size, w.period = file, size, period
+	return old.Close()
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var rotateErr error
+	if w.needRotate(len(p)) {
+		// on error the record goes to the current file, rotation is retried by the next write
+		rotateErr = w.rotate()
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err == nil && rotateErr != nil {
+		err = fmt.Errorf("rotate log file error: %w", rotateErr)
+	}
+	return n, err
+}
+
+// reopen file by path, records are not written while the file is swapped;
+// if the new file can't be opened writing continues to the old one
+func (w *fileWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.swap()
+}
+
+func (w *fileWriter) Close() error {
+	w.mu.Lock()
+	err := w.file.Close()
+	w.mu.Unlock()
+	w.millWg.Wait()
+	return err
+}
+
+func (w *fileWriter) periodStart(t time.Time) time.Time {
+	switch w.cfg.Rotate {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+func (w *fileWriter) needRotate(n int) bool {
+	if w.cfg.MaxSize > 0 && w.size > 0 && w.size+int64(n) > w.cfg.MaxSize {
+		return true
+	}
+	return !w.period.Equal(w.periodStart(time.Now()))
+}
+
+// must be called with w.mu held, the current file is kept open until the new one is opened
+func (w *fileWriter) rotate() error {
+	if err := os.Rename(w.cfg.FilePath, w.backupName(time.Now())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.swap(); err != nil {
+		return err
+	}
+	w.millWg.Add(1)
+	go func() {
+		defer w.millWg.Done()
+		w.mill()
+	}()
+	return nil
+}
+
+func (w *fileWriter) backupParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(w.cfg.FilePath)
+	base := filepath.Base(w.cfg.FilePath)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// unique backup file name: <name>-<time><ext>
+func (w *fileWriter) backupName(t time.Time) string {
+	dir, prefix, ext := w.backupParts()
+	for {
+		name := filepath.Join(dir, prefix+t.Format(backupTimeFormat)+ext)
+		_, err := os.Stat(name)
+		_, errgz := os.Stat(name + ".gz")
+		if os.IsNotExist(err) && os.IsNotExist(errgz) {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+type backupFile struct {
+	path string
+	tm   time.Time
+}
+
+// sorted by time backups, newest first
+func (w *fileWriter) backups() ([]backupFile, error) {
+	dir, prefix, ext := w.backupParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]backupFile, 0)
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], ".gz"), ext)
+		tm, err := time.ParseInLocation(backupTimeFormat, ts, time.Local)
+		if err != nil {
+			continue // not a backup
+		}
+		backups = append(backups, backupFile{filepath.Join(dir, name), tm})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].tm.After(backups[j].tm)
+	})
+	return backups, nil
+}
+
+// remove outdated backups and compress the rest
+func (w *fileWriter) mill() {
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+
+	backups, err := w.backups()
+	if err != nil {
+		return
+	}
+	for i, b := range backups {
+		if (w.cfg.MaxBackups > 0 && i >= w.cfg.MaxBackups) ||
+			(w.cfg.MaxAge > 0 && time.Since(b.tm) > w.cfg.MaxAge) {
+			os.Remove(b.path)
+			continue
+		}
+		if w.cfg.Compress && !strings.HasSuffix(b.path, ".gz") {
+			compressFile(b.path)
+		}
+	}
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
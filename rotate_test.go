@@ -0,0 +1,185 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFileWriterReopenFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.log")
+	w, err := newFileWriter(&FileOutConfig{FilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reopen(); err == nil {
+		t.Fatal("reopen of missing directory succeeded")
+	}
+	if _, err := w.Write([]byte("kept\n")); err != nil {
+		t.Fatalf("write after failed reopen: %v", err)
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if _, err := w.Write([]byte("after\n")); err != nil {
+		t.Fatalf("write after reopen: %v", err)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Fatalf("file content %q, want %q", got, "after\n")
+	}
+}
+
+// wait for background backup processing
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func backupFiles(t *testing.T, w *fileWriter) []string {
+	t.Helper()
+	dir, prefix, _ := w.backupParts()
+	files, err := filepath.Glob(filepath.Join(dir, prefix+"*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestFileWriterRotateSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := newFileWriter(&FileOutConfig{FilePath: path, MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for _, s := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readFile(t, path); got != "fourth\n" {
+		t.Fatalf("file content %q, want %q", got, "fourth\n")
+	}
+	waitFor(t, func() bool { return len(backupFiles(t, w)) == 2 })
+	backups := backupFiles(t, w) // sorted by time
+	if got := readFile(t, backups[0]); got != "second\n" {
+		t.Fatalf("oldest kept backup %q, want %q", got, "second\n")
+	}
+	if got := readFile(t, backups[1]); got != "third\n" {
+		t.Fatalf("newest backup %q, want %q", got, "third\n")
+	}
+}
+
+func TestFileWriterRotatePeriod(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := newFileWriter(&FileOutConfig{FilePath: path, Rotate: RotateDaily, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("yesterday\n")); err != nil {
+		t.Fatal(err)
+	}
+	w.mu.Lock()
+	w.period = w.period.AddDate(0, 0, -1)
+	w.mu.Unlock()
+	if _, err := w.Write([]byte("today\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "today\n" {
+		t.Fatalf("file content %q, want %q", got, "today\n")
+	}
+	waitFor(t, func() bool {
+		files := backupFiles(t, w)
+		return len(files) == 1 && strings.HasSuffix(files[0], ".gz")
+	})
+}
+
+func TestFileWriterRotateFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.log")
+	w, err := newFileWriter(&FileOutConfig{FilePath: path, MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("first line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	n, err := w.Write([]byte("kept\n"))
+	if err == nil {
+		t.Fatal("rotation into missing directory succeeded")
+	}
+	if n != len("kept\n") {
+		t.Fatalf("written %d bytes after failed rotation, want %d", n, len("kept\n"))
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("recovered\n")); err != nil {
+		t.Fatalf("write after directory is restored: %v", err)
+	}
+	if got := readFile(t, path); got != "recovered\n" {
+		t.Fatalf("file content %q, want %q", got, "recovered\n")
+	}
+}
+
+func TestFileWriterCloseWaitsForBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := newFileWriter(&FileOutConfig{FilePath: path, MaxSize: 10, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"first line\n", "second line\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files := backupFiles(t, w)
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".gz") {
+		t.Fatalf("backups %v after close, want one compressed", files)
+	}
+}
@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type SlogHandlerOptions struct {
+	// minimal slog level, all records are handled if nil
+	Level slog.Leveler
+	// groups become sublogger prefixes instead of dotted param keys
+	GroupPrefix bool
+}
+
+// slog.Handler writing records to logger outputs
+type SlogHandler struct {
+	l     *Logger
+	opts  SlogHandlerOptions
+	group string // dotted key prefix of open groups
+}
+
+func NewSlogHandler(l *Logger, opts *SlogHandlerOptions) *SlogHandler {
+	h := &SlogHandler{l: l}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func slogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return LevelTrace
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	}
+	return LevelError
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.opts.Level == nil || level >= h.opts.Level.Level()
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := h.l.fields
+	if ctxFields := ContextFields(ctx); len(ctxFields) > 0 {
+		fields = mergeFields(fields, ctxFields)
+	}
+	var attrs []Field
+	if r.NumAttrs() > 0 {
+		attrs = make([]Field, 0, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			attrs = appendAttr(attrs, h.group, a)
+			return true
+		})
+		fields = append(fields[:len(fields):len(fields)], attrs...)
+	}
+	rec := &Record{
+		Time:    r.Time,
+		Level:   slogLevel(r.Level),
+		Prefix:  h.l.prefix,
+		Fields:  fields,
+		Message: r.Message,
+	}
+	if rec.Time.IsZero() { // zero time is allowed by slog.Handler
+		rec.Time = time.Now()
+	}
+	if h.l.caller && r.PC != 0 {
+		rec.Caller = callerFromPC(r.PC)
+	}
+	if h.l.stackLevel != LevelUnknown && rec.Level >= h.l.stackLevel {
+		rec.Stack = captureStack()
+		var errs []any
+		for _, f := range attrs {
+			if f.kind == errorField {
+				errs = append(errs, f.iface)
+			}
+		}
+		rec.Errors = errorChain(errs)
+	}
+	h.l.write(rec)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make([]Field, 0, len(attrs))
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.group, a)
+	}
+	child := *h
+	child.l = h.l.With(fields...)
+	return &child
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	child := *h
+	if h.opts.GroupPrefix {
+		child.l = h.l.New(name)
+	} else {
+		child.group = h.group + name + "."
+	}
+	return &child
+}
+
+func appendAttr(fields []Field, group string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	key := group + a.Key
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		if a.Key != "" {
+			group = key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			fields = appendAttr(fields, group, ga)
+		}
+		return fields
+	case slog.KindString:
+		return append(fields, String(key, a.Value.String()))
+	case slog.KindInt64:
+		return append(fields, Int64(key, a.Value.Int64()))
+	case slog.KindFloat64:
+		return append(fields, Float64(key, a.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, Bool(key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, Duration(key, a.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, Time(key, a.Value.Time()))
+	}
+	return append(fields, Any(key, a.Value.Any()))
+}
@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// output keeping logged records, replaces std to keep test output clean
+type recordOut struct {
+	mu   sync.Mutex
+	recs []*Record
+}
+
+func (o *recordOut) Close() error   { return nil }
+func (o *recordOut) Init(_ *Logger) {}
+func (o *recordOut) Name() string   { return "std" }
+func (o *recordOut) Flush()         {}
+
+func (o *recordOut) Log(r *Record) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.recs = append(o.recs, r)
+}
+
+func (o *recordOut) last(t *testing.T) *Record {
+	t.Helper()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.recs) == 0 {
+		t.Fatal("no records logged")
+	}
+	return o.recs[len(o.recs)-1]
+}
+
+func fieldsString(fields []Field) string {
+	var s string
+	for _, f := range fields {
+		s += fmt.Sprintf("%s=%v ", f.Key, f.Value())
+	}
+	return s
+}
+
+func TestSlogHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       *SlogHandlerOptions
+		log        func(l *slog.Logger)
+		wantPrefix string
+		wantFields string
+	}{
+		{
+			name: "attrs",
+			log: func(l *slog.Logger) {
+				l.Info("msg", "s", "v", "i", 1, "f", 1.5, "b", true, "d", time.Second, slog.Any("list", []int{1}))
+			},
+			wantFields: "s=v i=1 f=1.5 b=true d=1s list=[1] ",
+		},
+		{
+			name: "group attr",
+			log: func(l *slog.Logger) {
+				l.Info("msg", slog.Group("req", "id", 7, slog.Group("user", "name", "bob")), slog.Group("", "inline", 1))
+			},
+			wantFields: "req.id=7 req.user.name=bob inline=1 ",
+		},
+		{
+			name:       "empty group",
+			log:        func(l *slog.Logger) { l.Info("msg", slog.Group("empty"), "a", 1) },
+			wantFields: "a=1 ",
+		},
+		{
+			name:       "with group",
+			log:        func(l *slog.Logger) { l.WithGroup("req").WithGroup("").Info("msg", "id", 7) },
+			wantFields: "req.id=7 ",
+		},
+		{
+			name:       "with attrs",
+			log:        func(l *slog.Logger) { l.With("app", "test").WithGroup("req").With("id", 7).Info("msg", "n", 1) },
+			wantFields: "app=test req.id=7 req.n=1 ",
+		},
+		{
+			name:       "group prefix",
+			opts:       &SlogHandlerOptions{GroupPrefix: true},
+			log:        func(l *slog.Logger) { l.WithGroup("db").WithGroup("pool").Info("msg", "n", 1) },
+			wantPrefix: "db/pool",
+			wantFields: "n=1 ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &recordOut{}
+			tt.log(slog.New(NewSlogHandler(NewLogger(out), tt.opts)))
+			r := out.last(t)
+			if r.Message != "msg" || r.Level != LevelInfo {
+				t.Errorf("record %v %q, want info %q", r.Level, r.Message, "msg")
+			}
+			if r.Prefix != tt.wantPrefix {
+				t.Errorf("prefix %q, want %q", r.Prefix, tt.wantPrefix)
+			}
+			if got := fieldsString(r.Fields); got != tt.wantFields {
+				t.Errorf("fields %q, want %q", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestSlogHandlerRecord(t *testing.T) {
+	out := &recordOut{}
+	h := NewSlogHandler(NewLogger(out).WithStack(LevelError).WithCaller(true), nil)
+
+	before := time.Now()
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelWarn, "no time", 0)); err != nil {
+		t.Fatal(err)
+	}
+	r := out.last(t)
+	if r.Time.Before(before) {
+		t.Errorf("time %v of record without time, want current", r.Time)
+	}
+	if r.Stack != "" || r.Caller != nil {
+		t.Errorf("stack %q, caller %v below stack level and without pc", r.Stack, r.Caller)
+	}
+
+	err := fmt.Errorf("outer: %w", errors.New("inner"))
+	slog.New(h).Error("failed", "error", err)
+	r = out.last(t)
+	if r.Stack == "" || r.Caller == nil {
+		t.Errorf("no stack or caller at stack level")
+	}
+	if got := fmt.Sprint(r.Errors); got != "[outer: inner inner]" {
+		t.Errorf("errors %s, want chain of the error attr", got)
+	}
+}
@@ -0,0 +1,308 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	spillExt         = ".seg"
+	spillSegmentSize = 1 << 20 // segment is sealed after this size
+	spillMinBackoff  = time.Second
+	spillMaxBackoff  = 5 * time.Minute
+)
+
+var errSpillFull = errors.New("spill size limit reached")
+
+// on-disk queue of records, append-only segments of json lines
+// replayed and removed in the order of writing
+type spillQueue struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64 // 0 - unlimited
+	size    int64
+	segs    []spillSegment
+	seq     int64
+	file    *os.File // open last segment, nil if sealed
+	backoff time.Duration
+	retryAt time.Time
+}
+
+type spillSegment struct {
+	path string
+	size int64
+}
+
+// record stored in a segment
+type spillRecord struct {
+	Time    time.Time    `json:"ts"`
+	Level   Level        `json:"level"`
+	Prefix  string       `json:"prefix,omitempty"`
+	Fields  []spillField `json:"fields,omitempty"`
+	Message string       `json:"msg"`
+	Caller  *Caller      `json:"caller,omitempty"`
+	Stack   string       `json:"stack,omitempty"`
+	Errors  []string     `json:"errors,omitempty"`
+}
+
+type spillField struct {
+	Key  string          `json:"k"`
+	Kind fieldKind       `json:"t"`
+	Num  int64           `json:"n,omitempty"`
+	Str  string          `json:"s,omitempty"`
+	Any  json.RawMessage `json:"a,omitempty"`
+}
+
+// open queue, segments left by previous runs are kept for replay
+func newSpillQueue(dir string, maxSize int64) (*spillQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create spill dir error: %v", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+spillExt))
+	if err != nil {
+		return nil, fmt.Errorf("list spill dir error: %v", err)
+	}
+	sort.Strings(paths) // zero-padded sequence numbers
+	q := &spillQueue{dir: dir, maxSize: maxSize}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("stat spill segment error: %v", err)
+		}
+		q.segs = append(q.segs, spillSegment{path, info.Size()})
+		q.size += info.Size()
+		fmt.Sscanf(filepath.Base(path), "%d", &q.seq)
+	}
+	return q, nil
+}
+
+// true if there are records to replay
+func (q *spillQueue) pending() bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.segs) > 0
+}
+
+// time left until the next replay, false if there is nothing to replay
+func (q *spillQueue) retryIn() (time.Duration, bool) {
+	if q == nil {
+		return 0, false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.segs) == 0 {
+		return 0, false
+	}
+	return max(time.Until(q.retryAt), 0), true
+}
+
+// true if records are pending and backoff has passed
+func (q *spillQueue) ready() bool {
+	d, ok := q.retryIn()
+	return ok && d == 0
+}
+
+// insert failed, delay the next replay
+func (q *spillQueue) failed() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.backoff = min(max(q.backoff*2, spillMinBackoff), spillMaxBackoff)
+	q.retryAt = time.Now().Add(q.backoff)
+}
+
+// append records to the last segment
+func (q *spillQueue) push(batch []*Record) error {
+	buf := make([]byte, 0, 256*len(batch))
+	for _, r := range batch {
+		line, err := json.Marshal(newSpillRecord(r))
+		if err != nil {
+			return fmt.Errorf("encode record error: %v", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.maxSize > 0 && q.size+int64(len(buf)) > q.maxSize {
+		return errSpillFull
+	}
+	if q.file == nil {
+		q.seq++
+		path := filepath.Join(q.dir, fmt.Sprintf("%020d%s", q.seq, spillExt))
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("create spill segment error: %v", err)
+		}
+		q.file = file
+		q.segs = append(q.segs, spillSegment{path: path})
+	}
+	n, err := q.file.Write(buf)
+	last := &q.segs[len(q.segs)-1]
+	last.size += int64(n)
+	q.size += int64(n)
+	if err == nil {
+		err = q.file.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("write spill segment error: %v", err)
+	}
+	if last.size >= q.segmentSize() {
+		q.seal()
+	}
+	return nil
+}
+
+func (q *spillQueue) segmentSize() int64 {
+	if q.maxSize > 0 {
+		return min(spillSegmentSize, max(q.maxSize/8, 1))
+	}
+	return spillSegmentSize
+}
+
+// close the last segment, next push starts a new one
+func (q *spillQueue) seal() {
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+}
+
+// records of the oldest segment, broken lines are skipped
+func (q *spillQueue) peek() ([]*Record, error) {
+	q.mu.Lock()
+	if len(q.segs) == 0 {
+		q.mu.Unlock()
+		return nil, nil
+	}
+	if len(q.segs) == 1 {
+		q.seal()
+	}
+	path := q.segs[0].path
+	q.mu.Unlock()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open spill segment error: %v", err)
+	}
+	defer file.Close()
+	var batch []*Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, math.MaxInt32)
+	for scanner.Scan() {
+		var sr spillRecord
+		if json.Unmarshal(scanner.Bytes(), &sr) == nil {
+			batch = append(batch, sr.record())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read spill segment error: %v", err)
+	}
+	return batch, nil
+}
+
+// remove the oldest segment after its records are inserted
+func (q *spillQueue) pop() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.segs) == 0 {
+		return nil
+	}
+	seg := q.segs[0]
+	q.segs = q.segs[1:]
+	q.size -= seg.size
+	q.backoff = 0
+	q.retryAt = time.Time{}
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove spill segment error: %v", err)
+	}
+	return nil
+}
+
+func (q *spillQueue) close() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seal()
+}
+
+func newSpillRecord(r *Record) *spillRecord {
+	sr := &spillRecord{
+		Time:    r.Time,
+		Level:   r.Level,
+		Prefix:  r.Prefix,
+		Message: r.Message,
+		Caller:  r.Caller,
+		Stack:   r.Stack,
+		Errors:  r.Errors,
+	}
+	if len(r.Fields) > 0 {
+		sr.Fields = make([]spillField, len(r.Fields))
+	}
+	for i, f := range r.Fields {
+		sf := spillField{Key: f.Key, Kind: f.kind, Num: f.num}
+		switch f.kind {
+		case stringField:
+			sf.Str = f.str
+		case timeField:
+			sf.Str = f.String()
+		case errorField: // restored as string
+			sf.Kind, sf.Num, sf.Str = stringField, 0, f.String()
+		case anyField:
+			if data, err := json.Marshal(f.iface); err == nil {
+				sf.Any = data
+			} else {
+				sf.Kind, sf.Str = stringField, f.String()
+			}
+		}
+		sr.Fields[i] = sf
+	}
+	return sr
+}
+
+func (sr *spillRecord) record() *Record {
+	r := &Record{
+		Time:    sr.Time,
+		Level:   sr.Level,
+		Prefix:  sr.Prefix,
+		Message: sr.Message,
+		Caller:  sr.Caller,
+		Stack:   sr.Stack,
+		Errors:  sr.Errors,
+	}
+	if len(sr.Fields) > 0 {
+		r.Fields = make([]Field, len(sr.Fields))
+	}
+	for i, sf := range sr.Fields {
+		f := Field{Key: sf.Key, kind: sf.Kind, num: sf.Num, str: sf.Str}
+		switch sf.Kind {
+		case timeField:
+			tm, _ := time.Parse(time.RFC3339Nano, sf.Str)
+			f.str, f.iface = "", tm
+		case anyField:
+			var v any
+			if len(sf.Any) > 0 {
+				json.Unmarshal(sf.Any, &v)
+			}
+			f.iface = v
+		default:
+			if sf.Kind >= errorField { // not written, broken line
+				f.kind = stringField
+			}
+		}
+		r.Fields[i] = f
+	}
+	return r
+}
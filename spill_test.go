@@ -0,0 +1,214 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func spillRoundTrip(t *testing.T, r *Record) *Record {
+	t.Helper()
+	data, err := json.Marshal(newSpillRecord(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sr spillRecord
+	if err := json.Unmarshal(data, &sr); err != nil {
+		t.Fatal(err)
+	}
+	return sr.record()
+}
+
+func TestSpillRecordRoundTrip(t *testing.T) {
+	tm := time.Date(2300, 1, 2, 3, 4, 5, 6, time.FixedZone("X", 3600))
+	r := &Record{
+		Time:   time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Level:  LevelWarn,
+		Prefix: "app/db",
+		Fields: []Field{
+			String("string", "a \"b\"\n"),
+			Int("int", -42),
+			Float64("float", 1.5),
+			Bool("true", true),
+			Bool("false", false),
+			Duration("duration", 1500*time.Millisecond),
+			Time("time", tm),
+			Time("zero", time.Time{}),
+			Err(fmt.Errorf("outer: %w", errors.New("inner"))),
+			NamedErr("nil", nil),
+			Any("map", map[string]int{"a": 1}),
+		},
+		Message: "message",
+		Caller:  &Caller{File: "/src/app/main.go", Line: 10, Function: "main.main"},
+		Stack:   "main.main()\n\t/src/app/main.go:10",
+		Errors:  []string{"outer: inner", "inner"},
+	}
+	got := spillRoundTrip(t, r)
+
+	if !got.Time.Equal(r.Time) || got.Level != r.Level || got.Prefix != r.Prefix || got.Message != r.Message ||
+		!reflect.DeepEqual(got.Caller, r.Caller) || got.Stack != r.Stack || !reflect.DeepEqual(got.Errors, r.Errors) {
+		t.Errorf("record %+v, want %+v", got, r)
+	}
+	if len(got.Fields) != len(r.Fields) {
+		t.Fatalf("%d fields, want %d", len(got.Fields), len(r.Fields))
+	}
+	for i, f := range r.Fields {
+		g := got.Fields[i]
+		if g.Key != f.Key || g.String() != f.String() {
+			t.Errorf("field %s=%s, want %s=%s", g.Key, g.String(), f.Key, f.String())
+		}
+	}
+	if v, ok := got.Fields[6].Value().(time.Time); !ok || !v.Equal(tm) {
+		t.Errorf("time field %v, want %v", got.Fields[6].Value(), tm)
+	}
+	if got.Fields[8].kind != stringField {
+		t.Errorf("error field kind %v, want string", got.Fields[8].kind)
+	}
+	if got, want := fieldsJSON(got.Fields), fieldsJSON(r.Fields); got != want {
+		t.Errorf("fields json %s, want %s", got, want)
+	}
+}
+
+func spillMessages(t *testing.T, q *spillQueue) []string {
+	t.Helper()
+	var res []string
+	for q.pending() {
+		batch, err := q.peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range batch {
+			res = append(res, r.Message)
+		}
+		if err := q.pop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res
+}
+
+func spillPush(t *testing.T, q *spillQueue, msgs ...string) {
+	t.Helper()
+	batch := make([]*Record, len(msgs))
+	for i, msg := range msgs {
+		batch[i] = &Record{Level: LevelInfo, Message: msg}
+	}
+	if err := q.push(batch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpillQueueOrderAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := newSpillQueue(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spillPush(t, q, "1", "2")
+	q.seal()
+	spillPush(t, q, "3")
+	q.close()
+
+	q, err = newSpillQueue(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+	spillPush(t, q, "4", "5")
+	if got, want := spillMessages(t, q), []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed %v, want %v", got, want)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+spillExt)); len(files) != 0 || q.size != 0 {
+		t.Fatalf("left segments %v, size %d", files, q.size)
+	}
+}
+
+func TestSpillQueueMaxSize(t *testing.T) {
+	q, err := newSpillQueue(t.TempDir(), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+	n := 0
+	for ; n < 100; n++ {
+		err = q.push([]*Record{{Level: LevelInfo, Message: fmt.Sprint(n)}})
+		if err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, errSpillFull) {
+		t.Fatalf("push error %v after %d records, want %v", err, n, errSpillFull)
+	}
+	if q.size > q.maxSize {
+		t.Fatalf("spilled %d bytes over limit %d", q.size, q.maxSize)
+	}
+	if len(q.segs) < 2 {
+		t.Fatalf("%d segments, want the limit split into several", len(q.segs))
+	}
+	if _, err := q.peek(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.pop(); err != nil {
+		t.Fatal(err)
+	}
+	spillPush(t, q, "after pop")
+}
+
+func TestSpillQueueBrokenLines(t *testing.T) {
+	dir := t.TempDir()
+	good, _ := json.Marshal(newSpillRecord(&Record{Level: LevelInfo, Message: "good"}))
+	unknown := `{"ts":"2024-01-01T00:00:00Z","level":3,"msg":"unknown kind","fields":[{"k":"f","t":200,"s":"v"}]}`
+	data := "{broken\n" + string(good) + "\n\n" + unknown + "\n" + `{"msg":"trunc`
+	if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%020d%s", 1, spillExt)), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	q, err := newSpillQueue(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+	batch, err := q.peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 2 || batch[0].Message != "good" || batch[1].Message != "unknown kind" {
+		t.Fatalf("read %d records, want good and unknown kind", len(batch))
+	}
+	if f := batch[1].Fields[0]; f.kind != stringField || f.String() != "v" {
+		t.Fatalf("field of unknown kind %v %q, want string", f.kind, f.String())
+	}
+
+	// sequence continues after the existing segment
+	spillPush(t, q, "next")
+	if got := filepath.Base(q.segs[1].path); got != fmt.Sprintf("%020d%s", 2, spillExt) {
+		t.Fatalf("new segment %s", got)
+	}
+}
+
+func TestSpillQueueRemovedSegment(t *testing.T) {
+	q, err := newSpillQueue(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+	spillPush(t, q, "lost")
+	q.seal()
+	spillPush(t, q, "kept")
+	if err := os.Remove(q.segs[0].path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.peek(); err == nil {
+		t.Fatal("peek of removed segment succeeded")
+	}
+	if err := q.pop(); err != nil {
+		t.Fatalf("pop of removed segment: %v", err)
+	}
+	if got := spillMessages(t, q); !reflect.DeepEqual(got, []string{"kept"}) {
+		t.Fatalf("replayed %v, want [kept]", got)
+	}
+}
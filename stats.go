@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reasons of dropped records
+const (
+	DropQueueFull = "queue_full" // buffer or queue overflow
+	DropClosed    = "closed"     // logged after close
+	DropFailed    = "failed"     // write failed and the record is not kept
+	DropRejected  = "rejected"   // rejected by the server, see dead letter output
+	DropSpillFull = "spill_full" // spill size limit reached
+)
+
+// counters of a single output
+type OutStats struct {
+	Name       string            `json:"name"`
+	Written    uint64            `json:"written"`
+	Dropped    map[string]uint64 `json:"dropped,omitempty"` // by reason
+	Errors     uint64            `json:"errors"`
+	Flushes    uint64            `json:"flushes"`
+	FlushTime  time.Duration     `json:"flushTime"`  // total duration of flushes
+	QueueDepth int               `json:"queueDepth"` // records waiting to be written
+}
+
+// output exposing its counters to Logger.Stats
+type StatsProvider interface {
+	Stats() OutStats
+}
+
+// counters kept by outputs, safe for concurrent use
+type outStats struct {
+	written   atomic.Uint64
+	errors    atomic.Uint64
+	flushes   atomic.Uint64
+	flushTime atomic.Int64
+	mu        sync.Mutex
+	dropped   map[string]uint64
+}
+
+func (s *outStats) drop(reason string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dropped == nil {
+		s.dropped = make(map[string]uint64)
+	}
+	s.dropped[reason] += uint64(n)
+}
+
+// count record write result
+func (s *outStats) write(err error) {
+	if err != nil {
+		s.errors.Add(1)
+		return
+	}
+	s.written.Add(1)
+}
+
+// count flush started at start
+func (s *outStats) flushed(start time.Time) {
+	s.flushes.Add(1)
+	s.flushTime.Add(int64(time.Since(start)))
+}
+
+func (s *outStats) snapshot() OutStats {
+	res := OutStats{
+		Written:   s.written.Load(),
+		Errors:    s.errors.Load(),
+		Flushes:   s.flushes.Load(),
+		FlushTime: time.Duration(s.flushTime.Load()),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.dropped) > 0 {
+		res.Dropped = make(map[string]uint64, len(s.dropped))
+		for reason, n := range s.dropped {
+			res.Dropped[reason] = n
+		}
+	}
+	return res
+}
+
+// counters of outputs implementing StatsProvider in registration order
+func (l *Logger) Stats() []OutStats {
+	var res []OutStats
+	for _, o := range l.core.outs.all() {
+		if p, ok := o.out.(StatsProvider); ok {
+			s := p.Stats()
+			s.Name = o.name
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// http handler exporting Stats in prometheus text format
+func (l *Logger) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		fmt.Fprint(w, prometheusStats(l.Stats()))
+	})
+}
+
+func prometheusStats(stats []OutStats) string {
+	var sb strings.Builder
+	metric := func(name, typ, help string, value func(s *OutStats) string) {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for i := range stats {
+			fmt.Fprintf(&sb, "%s{output=\"%s\"} %s\n", name, promLabel(stats[i].Name), value(&stats[i]))
+		}
+	}
+	metric("logger_records_written_total", "counter", "Records written by output.",
+		func(s *OutStats) string { return fmt.Sprint(s.Written) })
+
+	sb.WriteString("# HELP logger_records_dropped_total Records dropped by output and reason.\n")
+	sb.WriteString("# TYPE logger_records_dropped_total counter\n")
+	for _, s := range stats {
+		reasons := make([]string, 0, len(s.Dropped))
+		for reason := range s.Dropped {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Fprintf(&sb, "logger_records_dropped_total{output=\"%s\",reason=\"%s\"} %d\n",
+				promLabel(s.Name), promLabel(reason), s.Dropped[reason])
+		}
+	}
+
+	metric("logger_write_errors_total", "counter", "Failed writes by output.",
+		func(s *OutStats) string { return fmt.Sprint(s.Errors) })
+	metric("logger_flushes_total", "counter", "Flushes by output.",
+		func(s *OutStats) string { return fmt.Sprint(s.Flushes) })
+	metric("logger_flush_seconds_total", "counter", "Total duration of flushes by output.",
+		func(s *OutStats) string { return fmt.Sprint(s.FlushTime.Seconds()) })
+	metric("logger_queue_depth", "gauge", "Records waiting to be written by output.",
+		func(s *OutStats) string { return fmt.Sprint(s.QueueDepth) })
+	return sb.String()
+}
+
+var promLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabel(s string) string {
+	return promLabelReplacer.Replace(s)
+}
@@ -6,31 +6,37 @@ import (
 )
 
 type StdOutConfig struct {
-	Enabled    bool  `json:"enabled" yaml:"enabled"`
-	LogLevel   Level `json:"logLevel" yaml:"logLevel"`
-	ForceDebug bool  `json:"forceDebug" yaml:"forceDebug"`
+	Enabled    bool   `json:"enabled" yaml:"enabled"`
+	LogLevel   Level  `json:"logLevel" yaml:"logLevel"`
+	ForceDebug bool   `json:"forceDebug" yaml:"forceDebug"`
+	Format     Format `json:"format" yaml:"format"`
 }
 
 var DefaultStdOutConfig *StdOutConfig = &StdOutConfig{
 	Enabled:    true,
 	LogLevel:   LevelError,
 	ForceDebug: false,
+	Format:     FormatText,
 }
 
 type StdOut struct {
-	out *log.Logger
-	err *log.Logger
-	cfg *StdOutConfig
+	out   *log.Logger
+	err   *log.Logger
+	cfg   *StdOutConfig
+	enc   Encoder
+	stats outStats
 }
 
 func NewStdOut(cfg *StdOutConfig) *StdOut {
 	if cfg == nil {
 		cfg = DefaultStdOutConfig
 	}
+	flags := cfg.Format.lflags(log.LstdFlags)
 	return &StdOut{
-		out: log.New(os.Stdout, "", log.LstdFlags),
-		err: log.New(os.Stderr, "", log.LstdFlags),
+		out: log.New(os.Stdout, "", flags),
+		err: log.New(os.Stderr, "", flags),
 		cfg: cfg,
+		enc: NewEncoder(cfg.Format, true),
 	}
 }
 
@@ -38,22 +44,28 @@ func (l *StdOut) Close() error {
 	return nil
 }
 
-func (l *StdOut) init(_ *Logger) {
+func (l *StdOut) Init(_ *Logger) {
 }
 
-func (l *StdOut) name() string {
+func (l *StdOut) Name() string {
 	return "std"
 }
 
-func (l *StdOut) flush() {
+func (l *StdOut) Flush() {
 }
 
-func (l *StdOut) log(level Level, s string, i *info) {
-	if level >= l.cfg.LogLevel || (level == LevelDebug && l.cfg.ForceDebug) {
-		if level == LevelError {
-			l.err.Print(format(level, true, s, i))
+func (l *StdOut) Log(r *Record) {
+	if r.Level.allowed(l.cfg.LogLevel, l.cfg.ForceDebug, true) {
+		if r.Level == LevelError {
+			l.stats.write(l.err.Output(2, l.enc.Encode(r)))
 		} else {
-			l.out.Print(format(level, true, s, i))
+			l.stats.write(l.out.Output(2, l.enc.Encode(r)))
 		}
 	}
 }
+
+func (l *StdOut) Stats() OutStats {
+	s := l.stats.snapshot()
+	s.Name = l.Name()
+	return s
+}
@@ -7,9 +7,12 @@ import (
 )
 
 type SyslogOutConfig struct {
-	Enabled  bool   `json:"enabled" yaml:"enabled"`
-	Facility string `json:"facility" yaml:"facility"`
-	Tag      string `json:"tag" yaml:"tag"`
+	Name       string `json:"name" yaml:"name"` // instance name, "syslog" by default
+	Enabled    bool   `json:"enabled" yaml:"enabled"`
+	Facility   string `json:"facility" yaml:"facility"`
+	Tag        string `json:"tag" yaml:"tag"`
+	LogLevel   Level  `json:"logLevel" yaml:"logLevel"`
+	ForceDebug bool   `json:"forceDebug" yaml:"forceDebug"`
 }
 
 var DefaultSyslogOutConfig = &SyslogOutConfig{
@@ -19,8 +22,10 @@ var DefaultSyslogOutConfig = &SyslogOutConfig{
 }
 
 type SyslogOut struct {
-	w   *syslog.Writer
-	std *BaseLogger
+	cfg   *SyslogOutConfig
+	w     *syslog.Writer
+	std   *BaseLogger
+	stats outStats
 }
 
 func NewSyslogOut(cfg *SyslogOutConfig) (*SyslogOut, error) {
@@ -29,7 +34,8 @@ func NewSyslogOut(cfg *SyslogOutConfig) (*SyslogOut, error) {
 		return nil, fmt.Errorf("syslog out init error: %w", err)
 	}
 	return &SyslogOut{
-		w: w,
+		cfg: cfg,
+		w:   w,
 	}, nil
 }
 
@@ -47,28 +53,44 @@ func (l *SyslogOut) levelFunc(level Level) func(string) error {
 		return l.w.Err
 	case LevelDebug:
 		return l.w.Debug
+	case LevelFatal, LevelPanic:
+		return l.w.Crit
 	default:
 		l.std.Warnf("unexpected log level: %v", level)
 		return l.w.Notice
 	}
 }
 
-func (l *SyslogOut) log(level Level, s string, i *info) {
-	msg := format(level, false, s, i)
-	if err := l.levelFunc(level)(msg); err != nil {
+func (l *SyslogOut) Log(r *Record) {
+	if !r.Level.allowed(l.cfg.LogLevel, l.cfg.ForceDebug, false) {
+		return
+	}
+	msg := format(r, false)
+	err := l.levelFunc(r.Level)(msg)
+	l.stats.write(err)
+	if err != nil {
 		l.std.Errorf("syslog write error: %v", err)
 	}
 }
 
-func (l *SyslogOut) init(main *Logger) {
-	l.std = main.New("syslog").Std()
+func (l *SyslogOut) Stats() OutStats {
+	s := l.stats.snapshot()
+	s.Name = l.Name()
+	return s
 }
 
-func (l *SyslogOut) name() string {
+func (l *SyslogOut) Init(main *Logger) {
+	l.std = main.New(l.Name()).Std()
+}
+
+func (l *SyslogOut) Name() string {
+	if l.cfg.Name != "" {
+		return l.cfg.Name
+	}
 	return "syslog"
 }
 
-func (l *SyslogOut) flush() {
+func (l *SyslogOut) Flush() {
 }
 
 func facility(s string) syslog.Priority {